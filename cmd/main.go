@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/cache"
 	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/controller"
 	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/routes"
 	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/scheduler"
@@ -65,6 +66,131 @@ func main() {
 	log.Print("log level was set to ", strings.ToUpper(level.String()))
 	colog.SetMinLevel(level)
 	threadness := StringToInt(os.Getenv("THREADNESS"))
+	if strategy := os.Getenv("SCHEDULE_STRATEGY"); len(strategy) > 0 {
+		if err := cache.SetDefaultStrategy(strategy); err != nil {
+			log.Printf("warning: SCHEDULE_STRATEGY=\"%s\" is invalid due to %v, falling back to \"%s\".", strategy, err, cache.GetDefaultStrategy().Name())
+		}
+	}
+	log.Print("schedule strategy was set to ", cache.GetDefaultStrategy().Name())
+	if ratio := os.Getenv("OVERCOMMIT_RATIO"); len(ratio) > 0 {
+		r, err := strconv.ParseFloat(ratio, 64)
+		if err == nil {
+			err = cache.SetDefaultOvercommitRatio(r)
+		}
+		if err != nil {
+			log.Printf("warning: OVERCOMMIT_RATIO=\"%s\" is invalid due to %v, falling back to %v.", ratio, err, cache.GetDefaultOvercommitRatio())
+		}
+	}
+	log.Print("overcommit ratio was set to ", cache.GetDefaultOvercommitRatio())
+	if reserved := os.Getenv("RESERVED_SHARES_PER_DEVICE"); len(reserved) > 0 {
+		r, err := strconv.Atoi(reserved)
+		if err != nil || r < 0 {
+			log.Printf("warning: RESERVED_SHARES_PER_DEVICE=\"%s\" is invalid, falling back to %d.", reserved, cache.GetDefaultReservedXPUShares())
+		} else {
+			cache.SetDefaultReservedXPUShares(uint(r))
+		}
+	}
+	log.Print("reserved shares per device was set to ", cache.GetDefaultReservedXPUShares())
+	if timeout := os.Getenv("GANG_TIMEOUT"); len(timeout) > 0 {
+		d, err := time.ParseDuration(timeout)
+		if err == nil {
+			err = cache.SetGangTimeout(d)
+		}
+		if err != nil {
+			log.Printf("warning: GANG_TIMEOUT=\"%s\" is invalid due to %v, falling back to %v.", timeout, err, cache.GetGangTimeout())
+		}
+	}
+	log.Print("gang timeout was set to ", cache.GetGangTimeout())
+	if usage := os.Getenv("FAIR_SHARE_NODE_USAGE"); len(usage) > 0 {
+		u, err := strconv.ParseFloat(usage, 64)
+		if err == nil {
+			err = cache.SetFairShareNodeUsage(u)
+		}
+		if err != nil {
+			log.Printf("warning: FAIR_SHARE_NODE_USAGE=\"%s\" is invalid due to %v, falling back to %v.", usage, err, cache.GetFairShareNodeUsage())
+		}
+	}
+	log.Print("fair share node usage was set to ", cache.GetFairShareNodeUsage())
+	if overage := os.Getenv("FAIR_SHARE_OVERAGE"); len(overage) > 0 {
+		o, err := strconv.ParseFloat(overage, 64)
+		if err == nil {
+			err = cache.SetFairShareOverage(o)
+		}
+		if err != nil {
+			log.Printf("warning: FAIR_SHARE_OVERAGE=\"%s\" is invalid due to %v, falling back to %v.", overage, err, cache.GetFairShareOverage())
+		}
+	}
+	log.Print("fair share overage was set to ", cache.GetFairShareOverage())
+	if name := os.Getenv("SCHEDULER_NAME"); len(name) > 0 {
+		cache.SetSchedulerName(name)
+	}
+	log.Print("scheduler name was set to ", cache.GetSchedulerName())
+	if threshold := os.Getenv("HIGH_PRIORITY_THRESHOLD"); len(threshold) > 0 {
+		t, err := strconv.ParseInt(threshold, 10, 32)
+		if err != nil {
+			log.Printf("warning: HIGH_PRIORITY_THRESHOLD=\"%s\" is invalid due to %v, the devices are not chosen by the priority.", threshold, err)
+		} else {
+			cache.SetHighPriorityThreshold(int32(t))
+		}
+	}
+	if threshold, found := cache.GetHighPriorityThreshold(); found {
+		high := os.Getenv("HIGH_PRIORITY_STRATEGY")
+		low := os.Getenv("LOW_PRIORITY_STRATEGY")
+		highStrategy, lowStrategy := cache.GetPriorityStrategies()
+		if len(high) == 0 {
+			high = highStrategy.Name()
+		}
+		if len(low) == 0 {
+			low = lowStrategy.Name()
+		}
+		if err := cache.SetPriorityStrategies(high, low); err != nil {
+			log.Printf("warning: HIGH_PRIORITY_STRATEGY=\"%s\" or LOW_PRIORITY_STRATEGY=\"%s\" is invalid due to %v, falling back to \"%s\" and \"%s\".", high, low, err, highStrategy.Name(), lowStrategy.Name())
+		}
+		highStrategy, lowStrategy = cache.GetPriorityStrategies()
+		log.Printf("high priority threshold was set to %d with strategy %s, and strategy %s for the others", threshold, highStrategy.Name(), lowStrategy.Name())
+	}
+	if fraction := os.Getenv("HIGH_PRIORITY_RESERVED_FRACTION"); len(fraction) > 0 {
+		f, err := strconv.ParseFloat(fraction, 64)
+		if err == nil {
+			err = cache.SetHighPriorityFraction(f)
+		}
+		if err != nil {
+			log.Printf("warning: HIGH_PRIORITY_RESERVED_FRACTION=\"%s\" is invalid due to %v, falling back to %v.", fraction, err, cache.GetHighPriorityFraction())
+		}
+	}
+	log.Print("high priority reserved fraction was set to ", cache.GetHighPriorityFraction())
+	temperature, power := cache.GetTelemetryThresholds()
+	if value := os.Getenv("TELEMETRY_MAX_TEMPERATURE"); len(value) > 0 {
+		t, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Printf("warning: TELEMETRY_MAX_TEMPERATURE=\"%s\" is invalid due to %v, falling back to %v.", value, err, temperature)
+		} else {
+			temperature = t
+		}
+	}
+	if value := os.Getenv("TELEMETRY_MAX_POWER"); len(value) > 0 {
+		p, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Printf("warning: TELEMETRY_MAX_POWER=\"%s\" is invalid due to %v, falling back to %v.", value, err, power)
+		} else {
+			power = p
+		}
+	}
+	if err := cache.SetTelemetryThresholds(temperature, power); err != nil {
+		log.Printf("warning: the telemetry thresholds are invalid due to %v, they are not checked.", err)
+	}
+	temperature, power = cache.GetTelemetryThresholds()
+	log.Printf("telemetry thresholds were set to temperature %v and power %v", temperature, power)
+	if staleness := os.Getenv("TELEMETRY_STALENESS"); len(staleness) > 0 {
+		d, err := time.ParseDuration(staleness)
+		if err == nil {
+			err = cache.SetTelemetryStaleness(d)
+		}
+		if err != nil {
+			log.Printf("warning: TELEMETRY_STALENESS=\"%s\" is invalid due to %v, falling back to %v.", staleness, err, cache.GetTelemetryStaleness())
+		}
+	}
+	log.Print("telemetry staleness was set to ", cache.GetTelemetryStaleness())
 
 	initKubeClient()
 	port := os.Getenv("PORT")
@@ -88,6 +214,12 @@ func main() {
 	go controller.Run(threadness, stopCh)
 
 	xpuPredicate := scheduler.NewXPUPredicate(clientset, controller.GetSchedulerCache())
+	xpuPrioritize := scheduler.NewXPUPrioritize(clientset, controller.GetSchedulerCache(), scheduler.PrioritizeWeights{
+		FreeShares:    StringToWeight(os.Getenv("PRIORITIZE_FREE_SHARES_WEIGHT"), 1),
+		Fragmentation: StringToWeight(os.Getenv("PRIORITIZE_FRAGMENTATION_WEIGHT"), 1),
+		Telemetry:     StringToWeight(os.Getenv("PRIORITIZE_TELEMETRY_WEIGHT"), 1),
+	})
+	xpuPreempt := scheduler.NewXPUPreempt(clientset, controller.GetSchedulerCache())
 	xpuBind := scheduler.NewXPUBind(clientset, controller.GetSchedulerCache())
 	xpuInspect := scheduler.NewXPUInspect(controller.GetSchedulerCache())
 
@@ -96,6 +228,8 @@ func main() {
 	routes.AddPProf(router)
 	routes.AddVersion(router)
 	routes.AddPredicate(router, xpuPredicate)
+	routes.AddPrioritize(router, xpuPrioritize)
+	routes.AddPreempt(router, xpuPreempt)
 	routes.AddBind(router, xpuBind)
 	routes.AddInspect(router, xpuInspect)
 
@@ -130,3 +264,16 @@ func StringToInt(sThread string) int {
 
 	return thread
 }
+
+func StringToWeight(sWeight string, defaultWeight int) int {
+	if len(sWeight) == 0 {
+		return defaultWeight
+	}
+
+	weight, err := strconv.Atoi(sWeight)
+	if err != nil || weight < 0 {
+		log.Printf("warning: weight \"%s\" is invalid, falling back to %d.", sWeight, defaultWeight)
+		return defaultWeight
+	}
+	return weight
+}
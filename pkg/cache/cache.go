@@ -25,19 +25,37 @@ type SchedulerCache struct {
 	// record the knownPod, it will be added when annotation ALIYUN_GPU_ID is added, and will be removed when complete and deleted
 	knownPods map[types.UID]*v1.Pod
 	nLock     *sync.RWMutex
+
+	// the pod groups waiting for all their members, namespace/name: group
+	groups map[string]*podGroup
+	gLock  *sync.Mutex
+
+	// the pods requesting XPU shares which wait to be scheduled, pod UID: pod
+	pendingPods map[types.UID]*v1.Pod
+	pLock       *sync.RWMutex
+
+	// the quota and the fair share are checked and the pod is reserved all together
+	qLock *sync.Mutex
 }
 
 func NewSchedulerCache(nLister corelisters.NodeLister, pLister corelisters.PodLister) *SchedulerCache {
 	return &SchedulerCache{
-		nodes:      make(map[string]*NodeInfo),
-		nodeLister: nLister,
-		podLister:  pLister,
-		knownPods:  make(map[types.UID]*v1.Pod),
-		nLock:      new(sync.RWMutex),
+		nodes:       make(map[string]*NodeInfo),
+		nodeLister:  nLister,
+		podLister:   pLister,
+		knownPods:   make(map[types.UID]*v1.Pod),
+		nLock:       new(sync.RWMutex),
+		groups:      make(map[string]*podGroup),
+		gLock:       new(sync.Mutex),
+		pendingPods: make(map[types.UID]*v1.Pod),
+		pLock:       new(sync.RWMutex),
+		qLock:       new(sync.Mutex),
 	}
 }
 
 func (cache *SchedulerCache) GetNodeinfos() []*NodeInfo {
+	cache.nLock.RLock()
+	defer cache.nLock.RUnlock()
 	nodes := []*NodeInfo{}
 	for _, n := range cache.nodes {
 		nodes = append(nodes, n)
@@ -77,6 +95,30 @@ func (cache *SchedulerCache) GetPod(name, namespace string) (*v1.Pod, error) {
 	return cache.podLister.Pods(namespace).Get(name)
 }
 
+// GetPodsOnNode gets the pods on the node by their UIDs, the pod not found is skipped
+func (cache *SchedulerCache) GetPodsOnNode(nodeName string, uids []types.UID) []*v1.Pod {
+	pods := []*v1.Pod{}
+	if len(uids) == 0 {
+		return pods
+	}
+	wanted := map[types.UID]bool{}
+	for _, uid := range uids {
+		wanted[uid] = true
+	}
+
+	all, err := cache.podLister.List(labels.Everything())
+	if err != nil {
+		log.Printf("warn: failed to list pods due to %v", err)
+		return pods
+	}
+	for _, pod := range all {
+		if pod.Spec.NodeName == nodeName && wanted[pod.UID] {
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
+
 // Get known pod from the pod UID
 func (cache *SchedulerCache) KnownPod(podUID types.UID) bool {
 	cache.nLock.RLock()
@@ -103,10 +145,10 @@ func (cache *SchedulerCache) AddOrUpdatePod(pod *v1.Pod) error {
 		// put it into known pod
 		cache.rememberPod(pod.UID, podCopy)
 	} else {
-		log.Printf("debug: pod [%s] in namespace [%s]'s GPU ID is [%d], it's illegal, skip",
+		log.Printf("debug: pod [%s] in namespace [%s]'s GPU IDs are %v, it's illegal, skip",
 			pod.Name,
 			pod.Namespace,
-			utils.GetGPUIDFromAnnotation(pod))
+			utils.GetGPUIDsFromAnnotation(pod))
 	}
 
 	return nil
@@ -134,26 +176,17 @@ func (cache *SchedulerCache) GetNodeInfo(name string) (*NodeInfo, error) {
 	}
 
 	cache.nLock.Lock()
-	defer cache.nLock.Unlock()
 	n, ok := cache.nodes[name]
-
 	if !ok {
 		n = NewNodeInfo(node)
 		cache.nodes[name] = n
-	} else {
-		// if the existing node turn from non XPU shares to XPU shares
-		if len(cache.nodes[name].devs) == 0 ||
-			utils.GetXPUSharesCapacity(n.node) <= 0 ||
-			utils.GetGPUCountInNode(n.node) <= 0 {
-			log.Printf("info: need update node [%s]", name)
-
-			// FIXME: the scenario that the number of devices changes from 0 to an positive number
-			cache.nodes[name].Reset(node)
-			log.Printf("info: node: [%s], labels from cache after been updated: %v", n.node.Name, n.node.Labels)
-		} else {
-			log.Printf("info: using the existing node [%s] infor", name)
-		}
-		log.Printf("debug: node [%s] with devices %v", name, n.devs)
+	}
+	cache.nLock.Unlock()
+
+	// the node is updated without the cache lock, so waiting for the allocation on the node
+	// doesn't stall the requests for the other nodes
+	if ok {
+		n.updateNode(node)
 	}
 	return n, nil
 }
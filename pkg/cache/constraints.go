@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// getExcludedDevices gets the devices which the pod can't use whatever XPU shares they have,
+// device index: reason
+func (n *NodeInfo) getExcludedDevices(pod *v1.Pod) (excluded deviceReasons) {
+	excluded = deviceReasons{}
+	n.excludeBySelector(pod, excluded)
+	n.excludeByAffinity(pod, excluded)
+	n.excludeByExclusivity(pod, excluded)
+	n.excludeByTaints(pod, excluded)
+	return excluded
+}
+
+// excludeBySelector excludes the devices whose attributes don't match the device selector of the pod
+func (n *NodeInfo) excludeBySelector(pod *v1.Pod, excluded deviceReasons) {
+	requirements, err := utils.GetDeviceSelectorFromAnnotation(pod)
+	if err != nil {
+		for id := range n.devs {
+			excluded[id] = fmt.Sprintf("invalid device selector: %v", err)
+		}
+		return
+	}
+
+	if len(requirements) > 0 {
+		attributes := utils.GetDeviceAttributes(n.node)
+		for id := range n.devs {
+			if _, found := excluded[id]; found {
+				continue
+			}
+			for _, requirement := range requirements {
+				if !matchDeviceRequirement(requirement, attributes[id]) {
+					excluded[id] = fmt.Sprintf("excluded by device selector %s %s %v (%s=%q)",
+						requirement.Key,
+						requirement.Operator,
+						requirement.Values,
+						requirement.Key,
+						attributes[id][requirement.Key])
+					break
+				}
+			}
+		}
+	}
+}
+
+// excludeByAffinity excludes the devices against the device affinity and anti-affinity of the pod,
+// and the devices with the pods whose device anti-affinity matches the pod. Only the pods
+// in the same namespace are matched, like the pod affinity of Kubernetes.
+func (n *NodeInfo) excludeByAffinity(pod *v1.Pod, excluded deviceReasons) {
+	affinity, err := utils.GetDeviceAffinityFromAnnotation(pod, utils.EnvResourceAffinity)
+	if err != nil {
+		for id := range n.devs {
+			excluded[id] = fmt.Sprintf("invalid device affinity: %v", err)
+		}
+		return
+	}
+	antiAffinity, err := utils.GetDeviceAffinityFromAnnotation(pod, utils.EnvResourceAntiAffinity)
+	if err != nil {
+		for id := range n.devs {
+			excluded[id] = fmt.Sprintf("invalid device anti-affinity: %v", err)
+		}
+		return
+	}
+
+	podLabels := labels.Set(pod.Labels)
+	affinityMatched := map[int]bool{}
+	for id, dev := range n.devs {
+		for _, p := range dev.getActivePods() {
+			if p.UID == pod.UID || p.Namespace != pod.Namespace {
+				continue
+			}
+			if affinity != nil && affinity.Matches(labels.Set(p.Labels)) {
+				affinityMatched[id] = true
+			}
+			if _, found := excluded[id]; found {
+				continue
+			}
+			if antiAffinity != nil && antiAffinity.Matches(labels.Set(p.Labels)) {
+				excluded[id] = fmt.Sprintf("excluded by device anti-affinity with pod %s/%s", p.Namespace, p.Name)
+				continue
+			}
+			existing, err := utils.GetDeviceAffinityFromAnnotation(p, utils.EnvResourceAntiAffinity)
+			if err == nil && existing != nil && existing.Matches(podLabels) {
+				excluded[id] = fmt.Sprintf("excluded by device anti-affinity of pod %s/%s", p.Namespace, p.Name)
+			}
+		}
+	}
+
+	// the first pod of a group which has affinity to itself can be placed on any device
+	if affinity == nil || (len(affinityMatched) == 0 && affinity.Matches(podLabels)) {
+		return
+	}
+	for id := range n.devs {
+		if _, found := excluded[id]; !found && !affinityMatched[id] {
+			excluded[id] = fmt.Sprintf("excluded by device affinity %v", affinity)
+		}
+	}
+}
+
+// excludeByExclusivity excludes the devices held by the other exclusive pods, and the devices
+// with any pod if the pod is exclusive
+func (n *NodeInfo) excludeByExclusivity(pod *v1.Pod, excluded deviceReasons) {
+	exclusive := utils.IsExclusivePod(pod)
+	for id, dev := range n.devs {
+		if _, found := excluded[id]; found {
+			continue
+		}
+		if owner := dev.GetOwner(); owner != nil && owner.UID != pod.UID {
+			excluded[id] = fmt.Sprintf("exclusively held by pod %s/%s", owner.Namespace, owner.Name)
+			continue
+		}
+		if !exclusive {
+			continue
+		}
+		for _, p := range dev.getActivePods() {
+			if p.UID != pod.UID {
+				excluded[id] = fmt.Sprintf("not empty for the exclusive pod, used by pod %s/%s", p.Namespace, p.Name)
+				break
+			}
+		}
+	}
+}
+
+// excludeByTaints excludes the devices with the NoSchedule taints which the pod doesn't tolerate
+func (n *NodeInfo) excludeByTaints(pod *v1.Pod, excluded deviceReasons) {
+	for id, taint := range n.getUntoleratedTaints(pod, v1.TaintEffectNoSchedule) {
+		if _, found := excluded[id]; !found {
+			excluded[id] = fmt.Sprintf("excluded by device taint %s", taint.ToString())
+		}
+	}
+}
+
+// getAvoidedDevices gets the devices with the PreferNoSchedule taints which the pod doesn't tolerate,
+// and the devices hot by the telemetry, they are chosen only if no other device fits
+func (n *NodeInfo) getAvoidedDevices(pod *v1.Pod) (avoided map[int]bool) {
+	avoided = map[int]bool{}
+	for id := range n.getUntoleratedTaints(pod, v1.TaintEffectPreferNoSchedule) {
+		avoided[id] = true
+	}
+	for id := range n.getHotDevices() {
+		avoided[id] = true
+	}
+	return avoided
+}
+
+// getUntoleratedTaints gets the first taint of the effect which the pod doesn't tolerate on each device,
+// device index: taint
+func (n *NodeInfo) getUntoleratedTaints(pod *v1.Pod, effect v1.TaintEffect) (untolerated map[int]v1.Taint) {
+	untolerated = map[int]v1.Taint{}
+	taints := utils.GetDeviceTaints(n.node)
+	if len(taints) == 0 {
+		return untolerated
+	}
+
+	tolerations := utils.GetDeviceTolerationsFromAnnotation(pod)
+	for id, devTaints := range taints {
+		for i := range devTaints {
+			taint := devTaints[i]
+			if taint.Effect != effect {
+				continue
+			}
+			tolerated := false
+			for j := range tolerations {
+				if tolerations[j].ToleratesTaint(&taint) {
+					tolerated = true
+					break
+				}
+			}
+			if !tolerated {
+				untolerated[id] = taint
+				break
+			}
+		}
+	}
+	return untolerated
+}
+
+// matchDeviceRequirement checks the attributes of the device against the requirement,
+// Gt and Lt compare the values as dotted versions, e.g. "8.6" > "7.5" and "470.57.02" > "460.91"
+func matchDeviceRequirement(requirement v1.NodeSelectorRequirement, attributes map[string]string) bool {
+	value, found := attributes[requirement.Key]
+	switch requirement.Operator {
+	case v1.NodeSelectorOpIn:
+		if !found {
+			return false
+		}
+		for _, v := range requirement.Values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	case v1.NodeSelectorOpNotIn:
+		if !found {
+			return true
+		}
+		for _, v := range requirement.Values {
+			if v == value {
+				return false
+			}
+		}
+		return true
+	case v1.NodeSelectorOpExists:
+		return found
+	case v1.NodeSelectorOpDoesNotExist:
+		return !found
+	case v1.NodeSelectorOpGt, v1.NodeSelectorOpLt:
+		if !found || len(requirement.Values) != 1 {
+			return false
+		}
+		result, err := compareVersion(value, requirement.Values[0])
+		if err != nil {
+			return false
+		}
+		if requirement.Operator == v1.NodeSelectorOpGt {
+			return result > 0
+		}
+		return result < 0
+	default:
+		return false
+	}
+}
+
+// compareVersion compares the dotted numbers part by part, the missing part is 0
+func compareVersion(a, b string) (int, error) {
+	as := strings.Split(strings.TrimSpace(a), ".")
+	bs := strings.Split(strings.TrimSpace(b), ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		var err error
+		if i < len(as) {
+			if x, err = strconv.Atoi(as[i]); err != nil {
+				return 0, err
+			}
+		}
+		if i < len(bs) {
+			if y, err = strconv.Atoi(bs[i]); err != nil {
+				return 0, err
+			}
+		}
+		if x != y {
+			if x > y {
+				return 1, nil
+			}
+			return -1, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"testing"
+)
+
+func TestCompareVersion(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		result int
+		err    bool
+	}{
+		{a: "8.0", b: "8.0", result: 0},
+		{a: "8.6", b: "8.0", result: 1},
+		{a: "7.5", b: "8.0", result: -1},
+		{a: "470.57.02", b: "470.57", result: 1},
+		{a: "10.0", b: "9.9", result: 1},
+		{a: "8", b: "8.0.0", result: 0},
+		{a: " 8.0 ", b: "8.0", result: 0},
+		{a: "8.x", b: "8.0", err: true},
+	}
+
+	for _, test := range tests {
+		result, err := compareVersion(test.a, test.b)
+		if test.err {
+			if err == nil {
+				t.Errorf("compareVersion(%q, %q) = %d, want an error", test.a, test.b, result)
+			}
+			continue
+		}
+		if err != nil || result != test.result {
+			t.Errorf("compareVersion(%q, %q) = %d, %v, want %d", test.a, test.b, result, err, test.result)
+		}
+	}
+}
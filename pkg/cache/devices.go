@@ -13,6 +13,9 @@ type DeviceInfo struct {
 	idx		int
 	podMap		map[types.UID]*v1.Pod
 	totalXPUShares	uint
+	totalXPUCores	uint
+	// the pod requesting the whole device or the exclusive pod, it's empty if the device is shared
+	owner		types.UID
 	rwmu		*sync.RWMutex
 }
 
@@ -24,10 +27,11 @@ func (d *DeviceInfo) GetPods() []*v1.Pod {
 	return pods
 }
 
-func newDeviceInfo(index int, totalXPUShares uint) *DeviceInfo {
+func newDeviceInfo(index int, totalXPUShares uint, totalXPUCores uint) *DeviceInfo {
 	return &DeviceInfo{
 		idx:		index,
 		totalXPUShares:	totalXPUShares,
+		totalXPUCores:	totalXPUCores,
 		podMap:		map[types.UID]*v1.Pod{},
 		rwmu:		new(sync.RWMutex),
 	}
@@ -37,6 +41,25 @@ func (d *DeviceInfo) GetDevTotalXPUShares() uint {
 	return d.totalXPUShares
 }
 
+func (d *DeviceInfo) GetDevTotalXPUCores() uint {
+	return d.totalXPUCores
+}
+
+// GetOwner gets the running pod which exclusively owns the device, the pod requesting the whole device
+// or the exclusive pod requesting XPU shares, it's nil if the device is shared
+func (d *DeviceInfo) GetOwner() *v1.Pod {
+	d.rwmu.RLock()
+	defer d.rwmu.RUnlock()
+	if len(d.owner) == 0 {
+		return nil
+	}
+	pod, found := d.podMap[d.owner]
+	if !found || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return nil
+	}
+	return pod
+}
+
 func (d *DeviceInfo) GetDevUsedXPUShares() (gpuMem uint) {
 	//log.Printf("debug: devices pod map %v, and its address is %p", d.podMap, d)
 	d.rwmu.RLock()
@@ -46,11 +69,58 @@ func (d *DeviceInfo) GetDevUsedXPUShares() (gpuMem uint) {
 			log.Printf("debug: skip the pod [%s] in namespace [%s] due to its status is [%s]", pod.Name, pod.Namespace, pod.Status.Phase)
 			continue
 		}
-		gpuMem += utils.GetXPUSharesFromPodAnnotation(pod)
+		gpuMem += utils.GetXPUSharesByDevFromPodAnnotation(pod)[d.idx]
 	}
 	return gpuMem
 }
 
+// GetDevBurstXPUShares gets the XPU shares which the pods on the device can burst to, it's the same
+// as the used XPU shares if no pod can burst
+func (d *DeviceInfo) GetDevBurstXPUShares() (burst uint) {
+	d.rwmu.RLock()
+	defer d.rwmu.RUnlock()
+	for _, pod := range d.podMap {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		burst += utils.GetXPUBurstByDevFromPodAnnotation(pod)[d.idx]
+	}
+	return burst
+}
+
+func (d *DeviceInfo) GetDevUsedXPUCores() (cores uint) {
+	d.rwmu.RLock()
+	defer d.rwmu.RUnlock()
+	for _, pod := range d.podMap {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		cores += utils.GetXPUCoresByDevFromPodAnnotation(pod)[d.idx]
+	}
+	return cores
+}
+
+// getActivePods gets the pods on the device which are not completed
+func (d *DeviceInfo) getActivePods() []*v1.Pod {
+	d.rwmu.RLock()
+	defer d.rwmu.RUnlock()
+	pods := []*v1.Pod{}
+	for _, pod := range d.podMap {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	return pods
+}
+
+func (d *DeviceInfo) hasPod(uid types.UID) bool {
+	d.rwmu.RLock()
+	defer d.rwmu.RUnlock()
+	_, found := d.podMap[uid]
+	return found
+}
+
 func (d *DeviceInfo) addPod(pod *v1.Pod) {
 	log.Printf("debug: add pod [%s] in namespace [%s] with the GPU[%d] will be added to device map",
 		pod.Name,
@@ -59,6 +129,9 @@ func (d *DeviceInfo) addPod(pod *v1.Pod) {
 	d.rwmu.Lock()
 	defer d.rwmu.Unlock()
 	d.podMap[pod.UID] = pod
+	if utils.IsWholeDevicePod(pod) || utils.IsExclusivePod(pod) {
+		d.owner = pod.UID
+	}
 	//log.Printf("debug: add pod after updated is %v, and its address is %p", d.podMap, d)
 }
 
@@ -70,5 +143,8 @@ func (d *DeviceInfo) removePod(pod *v1.Pod) {
 	d.rwmu.Lock()
 	defer d.rwmu.Unlock()
 	delete(d.podMap, pod.UID)
+	if d.owner == pod.UID {
+		d.owner = ""
+	}
 	//log.Printf("debug: remove pod after updated is %v, and its address is %p", d.podMap, d)
 }
@@ -0,0 +1,309 @@
+package cache
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// TenantWeightConfigMap is the configmap in kube-system defining the weight of each namespace in the
+// fair share of the XPU shares, the key is the namespace and the value is the weight, e.g. team-a: "2".
+// The fair share is not enforced without the configmap, and the namespace not in it has the weight 1.
+const TenantWeightConfigMap = "xpu-tenant-weights"
+
+var (
+	// the usage of the node from which it's nearly full
+	fairShareNodeUsage = 0.9
+	// how much the namespace can use above its fair share
+	fairShareOverage = 1.5
+	// the scheduler which calls the extender, only its pending pods are counted
+	schedulerName = v1.DefaultSchedulerName
+	fairShareLock = new(sync.RWMutex)
+)
+
+// SetFairShareNodeUsage sets the usage of the XPU shares in the node from which the fair share is enforced
+func SetFairShareNodeUsage(usage float64) error {
+	if usage <= 0 || usage > 1 {
+		return fmt.Errorf("node usage %v is not in (0, 1]", usage)
+	}
+	fairShareLock.Lock()
+	defer fairShareLock.Unlock()
+	fairShareNodeUsage = usage
+	return nil
+}
+
+// GetFairShareNodeUsage gets the usage of the XPU shares in the node from which the fair share is enforced
+func GetFairShareNodeUsage() float64 {
+	fairShareLock.RLock()
+	defer fairShareLock.RUnlock()
+	return fairShareNodeUsage
+}
+
+// SetFairShareOverage sets how many times its fair share the namespace can use when the others are pending
+func SetFairShareOverage(overage float64) error {
+	if overage < 1 {
+		return fmt.Errorf("overage %v is less than 1", overage)
+	}
+	fairShareLock.Lock()
+	defer fairShareLock.Unlock()
+	fairShareOverage = overage
+	return nil
+}
+
+// GetFairShareOverage gets how many times its fair share the namespace can use when the others are pending
+func GetFairShareOverage() float64 {
+	fairShareLock.RLock()
+	defer fairShareLock.RUnlock()
+	return fairShareOverage
+}
+
+// SetSchedulerName sets the name of the scheduler which calls the extender
+func SetSchedulerName(name string) {
+	fairShareLock.Lock()
+	defer fairShareLock.Unlock()
+	schedulerName = name
+}
+
+// GetSchedulerName gets the name of the scheduler which calls the extender
+func GetSchedulerName() string {
+	fairShareLock.RLock()
+	defer fairShareLock.RUnlock()
+	return schedulerName
+}
+
+// TenantShare is the XPU shares used and pending in one namespace against its fair share
+type TenantShare struct {
+	Namespace     string
+	Weight        float64
+	UsedShares    uint
+	FairShares    uint
+	PendingPods   int
+	PendingShares uint
+}
+
+// getTenantWeights gets the weight of each namespace, namespace: weight, it's not found without the configmap
+func getTenantWeights() (weights map[string]float64, found bool) {
+	cm := getConfigMap(TenantWeightConfigMap)
+	if cm == nil {
+		return nil, false
+	}
+
+	weights = map[string]float64{}
+	for namespace, value := range cm.Data {
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight <= 0 {
+			log.Printf("warn: failed to parse the weight [%s] of namespace [%s] due to %v", value, namespace, err)
+			continue
+		}
+		weights[namespace] = weight
+	}
+	return weights, true
+}
+
+func getTenantWeight(weights map[string]float64, namespace string) float64 {
+	if weight, found := weights[namespace]; found {
+		return weight
+	}
+	return 1
+}
+
+// isPendingPod determines if the pod requesting XPU shares or whole devices waits to be scheduled by
+// the scheduler calling the extender, the pods of the other schedulers don't compete for the XPU shares
+func isPendingPod(pod *v1.Pod) bool {
+	name := pod.Spec.SchedulerName
+	if len(name) == 0 {
+		name = v1.DefaultSchedulerName
+	}
+	return len(pod.Spec.NodeName) == 0 && name == GetSchedulerName() && !utils.IsCompletePod(pod) && utils.IsGPUsharingPod(pod)
+}
+
+// UpdatePendingPod records the pod if it's pending, or forgets it if it's scheduled or complete
+func (cache *SchedulerCache) UpdatePendingPod(pod *v1.Pod) {
+	cache.pLock.Lock()
+	defer cache.pLock.Unlock()
+	if isPendingPod(pod) {
+		cache.pendingPods[pod.UID] = pod
+	} else {
+		delete(cache.pendingPods, pod.UID)
+	}
+}
+
+// RemovePendingPod forgets the deleted pod
+func (cache *SchedulerCache) RemovePendingPod(pod *v1.Pod) {
+	cache.pLock.Lock()
+	defer cache.pLock.Unlock()
+	delete(cache.pendingPods, pod.UID)
+}
+
+// getPendingDemand gets the pending pods and their XPU shares in each namespace,
+// namespace: pods and namespace: XPU shares
+func (cache *SchedulerCache) getPendingDemand() (pods map[string]int, shares map[string]uint) {
+	cache.pLock.RLock()
+	defer cache.pLock.RUnlock()
+	pods = map[string]int{}
+	shares = map[string]uint{}
+	for _, pod := range cache.pendingPods {
+		pods[pod.Namespace]++
+		shares[pod.Namespace] += uint(utils.GetRequestXPUSharesFromPodResource(pod))
+	}
+	return pods, shares
+}
+
+// getClusterXPUShares gets the XPU shares of all the nodes with the overcommit ratio
+func (cache *SchedulerCache) getClusterXPUShares() (total uint) {
+	for _, n := range cache.GetNodeinfos() {
+		for _, shares := range n.GetEffectiveXPUShares() {
+			total += shares
+		}
+	}
+	return total
+}
+
+// GetTenantShares gets the fair share of each namespace which uses XPU shares or has pending pods,
+// sorted by the namespace. The XPU shares of the cluster are divided by the weights of these namespaces.
+func (cache *SchedulerCache) GetTenantShares() []*TenantShare {
+	weights, found := getTenantWeights()
+	if !found {
+		return []*TenantShare{}
+	}
+	return cache.getTenantShares(weights, "")
+}
+
+// getTenantShares gets the fair share of each namespace, the XPU shares used by the pod are not counted
+func (cache *SchedulerCache) getTenantShares(weights map[string]float64, except types.UID) []*TenantShare {
+	pendingPods, pendingShares := cache.getPendingDemand()
+	tenants := map[string]*TenantShare{}
+	for _, n := range cache.GetNodeinfos() {
+		n.rwmu.RLock()
+		for _, dev := range n.devs {
+			for _, p := range dev.getActivePods() {
+				if p.UID == except {
+					continue
+				}
+				if _, found := tenants[p.Namespace]; !found {
+					tenants[p.Namespace] = &TenantShare{Namespace: p.Namespace}
+				}
+				tenants[p.Namespace].UsedShares += utils.GetXPUSharesByDevFromPodAnnotation(p)[dev.idx]
+			}
+		}
+		n.rwmu.RUnlock()
+	}
+	for namespace, pods := range pendingPods {
+		if _, found := tenants[namespace]; !found {
+			tenants[namespace] = &TenantShare{Namespace: namespace}
+		}
+		tenants[namespace].PendingPods = pods
+		tenants[namespace].PendingShares = pendingShares[namespace]
+	}
+
+	sumWeights := 0.0
+	for namespace, tenant := range tenants {
+		tenant.Weight = getTenantWeight(weights, namespace)
+		sumWeights += tenant.Weight
+	}
+	total := cache.getClusterXPUShares()
+	shares := []*TenantShare{}
+	for _, tenant := range tenants {
+		tenant.FairShares = uint(float64(total) * tenant.Weight / sumWeights)
+		shares = append(shares, tenant)
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		return shares[i].Namespace < shares[j].Namespace
+	})
+	return shares
+}
+
+// getFairShareLimit gets the XPU shares which the namespace of the pod can still use within its fair
+// share while the other namespaces have pending pods, it's not found without the weights or such pods
+func (cache *SchedulerCache) getFairShareLimit(pod *v1.Pod) (limit uint, found bool) {
+	weights, found := getTenantWeights()
+	if !found {
+		return 0, false
+	}
+
+	self := &TenantShare{Namespace: pod.Namespace, FairShares: cache.getClusterXPUShares()}
+	found = false
+	for _, tenant := range cache.getTenantShares(weights, pod.UID) {
+		if tenant.Namespace == pod.Namespace {
+			self = tenant
+		} else if tenant.PendingPods > 0 {
+			found = true
+		}
+	}
+	if !found {
+		return 0, false
+	}
+	if fair := uint(float64(self.FairShares) * GetFairShareOverage()); fair > self.UsedShares {
+		limit = fair - self.UsedShares
+	}
+	log.Printf("debug: namespace [%s] can use %d more XPU shares within its fair share %d while the others are pending",
+		pod.Namespace,
+		limit,
+		self.FairShares)
+	return limit, true
+}
+
+// CheckFairShare checks if the namespace of the pod placed on the nearly full node stays in its fair
+// share while the other namespaces have pending pods, it's not checked without the weights
+func (cache *SchedulerCache) CheckFairShare(pod *v1.Pod, n *NodeInfo) error {
+	weights, found := getTenantWeights()
+	if !found {
+		return nil
+	}
+
+	allocation, err := n.assumeXPUs(pod)
+	if err != nil {
+		return err
+	}
+	var reqShares uint
+	for _, shares := range allocation {
+		reqShares += shares
+	}
+	// the elastic pod fits if its minimum fits, it grows within the fair share when it's bound
+	if elastic, minShares, _ := utils.GetElasticXPUSharesFromAnnotation(pod); len(elastic) > 0 && minShares < reqShares {
+		reqShares = minShares
+	}
+
+	n.rwmu.RLock()
+	var allShares, availableShares uint
+	for _, shares := range n.getAllXPUs() {
+		allShares += shares
+	}
+	for _, shares := range n.getAvailableXPUs() {
+		availableShares += shares
+	}
+	n.rwmu.RUnlock()
+	if allShares == 0 || float64(allShares-availableShares+reqShares) < float64(allShares)*GetFairShareNodeUsage() {
+		return nil
+	}
+
+	// the pod is pending, so its namespace is one of the tenants
+	self := &TenantShare{Namespace: pod.Namespace, FairShares: cache.getClusterXPUShares()}
+	pending := []string{}
+	for _, tenant := range cache.getTenantShares(weights, pod.UID) {
+		if tenant.Namespace == pod.Namespace {
+			self = tenant
+		} else if tenant.PendingPods > 0 {
+			pending = append(pending, tenant.Namespace)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	if limit := float64(self.FairShares) * GetFairShareOverage(); float64(self.UsedShares+reqShares) > limit {
+		return fmt.Errorf("namespace %s exceeds its fair share of XPU shares (%d + %d > %d) while namespaces %v are pending",
+			pod.Namespace,
+			self.UsedShares,
+			reqShares,
+			uint(limit),
+			pending)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+)
+
+func TestIsPendingPod(t *testing.T) {
+	pending := func(schedulerName string, shares int64) *v1.Pod {
+		pod := newTestPod("pod", nil, xpuShares(shares))
+		pod.Spec.NodeName = ""
+		pod.Spec.SchedulerName = schedulerName
+		pod.Status.Phase = v1.PodPending
+		return pod
+	}
+	bound := pending("", 4)
+	bound.Spec.NodeName = "node-1"
+
+	tests := []struct {
+		name    string
+		pod     *v1.Pod
+		pending bool
+	}{
+		{name: "default scheduler", pod: pending(v1.DefaultSchedulerName, 4), pending: true},
+		{name: "scheduler name not set", pod: pending("", 4), pending: true},
+		{name: "other scheduler", pod: pending("other-scheduler", 4), pending: false},
+		{name: "no XPU shares", pod: pending(v1.DefaultSchedulerName, 0), pending: false},
+		{name: "bound", pod: bound, pending: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if pending := isPendingPod(test.pod); pending != test.pending {
+				t.Errorf("pending %v, want %v", pending, test.pending)
+			}
+		})
+	}
+}
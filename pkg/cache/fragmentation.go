@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"sync"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+	"k8s.io/api/core/v1"
+)
+
+const (
+	FragmentationStrategyName = "fragmentation-aware"
+
+	// the number of the recent requests kept to estimate the request sizes
+	requestHistorySize = 1000
+)
+
+// requestHistory is the XPU shares of the containers recently placed by the extender
+type requestHistory struct {
+	sizes []uint
+	next  int
+	// XPU shares: count in sizes
+	counts map[uint]int
+	rwmu   *sync.RWMutex
+}
+
+var recentRequests = &requestHistory{
+	sizes:  make([]uint, 0, requestHistorySize),
+	counts: map[uint]int{},
+	rwmu:   new(sync.RWMutex),
+}
+
+// record adds the XPU shares of the containers in the allocation of the pod to the history,
+// the init containers and the pods requesting whole devices are skipped
+func (h *requestHistory) record(pod *v1.Pod, allocation []utils.ContainerAllocation) {
+	if utils.IsWholeDevicePod(pod) {
+		return
+	}
+	h.rwmu.Lock()
+	defer h.rwmu.Unlock()
+	for _, container := range allocation {
+		if container.Init {
+			continue
+		}
+		var shares uint
+		for _, s := range container.Devices {
+			shares += s
+		}
+		if shares == 0 {
+			continue
+		}
+		if len(h.sizes) < requestHistorySize {
+			h.sizes = append(h.sizes, shares)
+		} else {
+			h.counts[h.sizes[h.next]]--
+			if h.counts[h.sizes[h.next]] == 0 {
+				delete(h.counts, h.sizes[h.next])
+			}
+			h.sizes[h.next] = shares
+			h.next = (h.next + 1) % requestHistorySize
+		}
+		h.counts[shares]++
+	}
+}
+
+// distribution gets the share of each request size in the history, XPU shares: share in [0, 1]
+func (h *requestHistory) distribution() map[uint]float64 {
+	h.rwmu.RLock()
+	defer h.rwmu.RUnlock()
+	dist := map[uint]float64{}
+	for size, count := range h.counts {
+		dist[size] = float64(count) / float64(len(h.sizes))
+	}
+	return dist
+}
+
+// usableXPUShares is the expected XPU shares of the device which the requests of the sizes can use,
+// e.g. 7 free shares can serve 2 requests of 3 shares, so 1 share is stranded for them
+func usableXPUShares(free uint, dist map[uint]float64) float64 {
+	var usable float64
+	for size, p := range dist {
+		if size > 0 {
+			usable += p * float64(free/size*size)
+		}
+	}
+	return usable
+}
+
+// fragmentation is the part of the free XPU shares in the devices which the recent requests can't use,
+// it's in [0, 1] and 0 is not fragmented. Without the history, it's the part not in the largest free device.
+func fragmentation(freeXPUShares []uint, dist map[uint]float64) float64 {
+	var total, largest uint
+	var usable float64
+	for _, free := range freeXPUShares {
+		total += free
+		if free > largest {
+			largest = free
+		}
+		usable += usableXPUShares(free, dist)
+	}
+	if total == 0 {
+		return 0
+	}
+	if len(dist) == 0 {
+		return 1 - float64(largest)/float64(total)
+	}
+	return 1 - usable/float64(total)
+}
+
+// GetFragmentation gets the fragmentation of the free XPU shares of the node, it's in [0, 1]
+func (n *NodeInfo) GetFragmentation() float64 {
+	n.rwmu.RLock()
+	defer n.rwmu.RUnlock()
+	return fragmentation(n.getFreeXPUShares(nil), recentRequests.distribution())
+}
+
+// GetFragmentation gets the fragmentation of the free XPU shares of all the nodes, it's in [0, 1]
+func (cache *SchedulerCache) GetFragmentation() float64 {
+	cache.nLock.RLock()
+	nodes := []*NodeInfo{}
+	for _, n := range cache.nodes {
+		nodes = append(nodes, n)
+	}
+	cache.nLock.RUnlock()
+
+	free := []uint{}
+	for _, n := range nodes {
+		n.rwmu.RLock()
+		free = append(free, n.getFreeXPUShares(nil)...)
+		n.rwmu.RUnlock()
+	}
+	return fragmentation(free, recentRequests.distribution())
+}
+
+// getFreeXPUShares gets the free XPU shares of each available device after the allocation
+func (n *NodeInfo) getFreeXPUShares(allocation map[int]uint) []uint {
+	free := []uint{}
+	for id, availableShares := range n.getAvailableXPUs() {
+		free = append(free, availableShares-allocation[id])
+	}
+	return free
+}
+
+// fragmentationStrategy prefers the device which keeps the most free XPU shares usable for the
+// recent requests, and the device with the least available shares if they are the same
+type fragmentationStrategy struct{}
+
+func (fragmentationStrategy) Name() string {
+	return FragmentationStrategyName
+}
+
+func (fragmentationStrategy) Score(devID int, availableShares uint, reqShares uint) int {
+	dist := recentRequests.distribution()
+	if len(dist) == 0 {
+		dist = map[uint]float64{reqShares: 1}
+	}
+	// the usable XPU shares lost by the placement, at most availableShares
+	lost := usableXPUShares(availableShares, dist) - usableXPUShares(availableShares-reqShares, dist)
+	return -int(lost*1000)*(1<<20) - int(availableShares)
+}
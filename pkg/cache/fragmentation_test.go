@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+)
+
+func TestFragmentation(t *testing.T) {
+	tests := []struct {
+		name          string
+		free          []uint
+		dist          map[uint]float64
+		fragmentation float64
+	}{
+		{name: "no free shares", free: []uint{0, 0}, fragmentation: 0},
+		{name: "one free device without history", free: []uint{16, 0}, fragmentation: 0},
+		{name: "not in the largest device without history", free: []uint{8, 4, 4}, fragmentation: 0.5},
+		{name: "the requests use all", free: []uint{6, 3}, dist: map[uint]float64{3: 1}, fragmentation: 0},
+		{name: "stranded shares", free: []uint{7}, dist: map[uint]float64{3: 1}, fragmentation: 1.0 / 7},
+		{name: "mixed request sizes", free: []uint{8}, dist: map[uint]float64{3: 0.5, 4: 0.5}, fragmentation: 1.0 / 8},
+		{name: "too small for the requests", free: []uint{2, 2}, dist: map[uint]float64{4: 1}, fragmentation: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if f := fragmentation(test.free, test.dist); math.Abs(f-test.fragmentation) > 1e-9 {
+				t.Errorf("fragmentation %v, want %v", f, test.fragmentation)
+			}
+		})
+	}
+}
+
+func TestFragmentationStrategy(t *testing.T) {
+	saved := recentRequests
+	defer func() { recentRequests = saved }()
+	recentRequests = &requestHistory{counts: map[uint]int{}, rwmu: new(sync.RWMutex)}
+	s := fragmentationStrategy{}
+
+	// without the history, the device with the least available shares wins the tie
+	if tight, loose := s.Score(0, 4, 4), s.Score(1, 8, 4); tight <= loose {
+		t.Errorf("score %d of the tight device is not more than %d", tight, loose)
+	}
+
+	// 7 free shares stay usable by the recent requests of 4 shares after placing 3 shares, 4 don't
+	pod := newTestPod("pod", nil, xpuShares(4))
+	recentRequests.record(pod, []utils.ContainerAllocation{{Name: "container-0", Devices: map[int]uint{0: 4}}})
+	if keep, strand := s.Score(0, 7, 3), s.Score(1, 4, 3); keep <= strand {
+		t.Errorf("score %d of keeping 4 usable shares is not more than %d of stranding them", keep, strand)
+	}
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// BindHTTPTimeout is the httpTimeout of the extender in the scheduler policy, the scheduler abandons
+// the bind of the member waiting longer than that
+const BindHTTPTimeout = 60 * time.Second
+
+var (
+	gangTimeout     = 30 * time.Second
+	gangTimeoutLock = new(sync.RWMutex)
+)
+
+// SetGangTimeout sets how long the tentative placements of a pod group are held
+// before all its members are placed, it must be shorter than BindHTTPTimeout
+func SetGangTimeout(timeout time.Duration) error {
+	if timeout <= 0 {
+		return fmt.Errorf("gang timeout %v is not positive", timeout)
+	}
+	if timeout >= BindHTTPTimeout {
+		return fmt.Errorf("gang timeout %v is not shorter than the bind timeout %v of the scheduler", timeout, BindHTTPTimeout)
+	}
+	gangTimeoutLock.Lock()
+	defer gangTimeoutLock.Unlock()
+	gangTimeout = timeout
+	return nil
+}
+
+// GetGangTimeout gets how long the tentative placements of a pod group are held
+func GetGangTimeout() time.Duration {
+	gangTimeoutLock.RLock()
+	defer gangTimeoutLock.RUnlock()
+	return gangTimeout
+}
+
+// podGroup is the pods placed all together, the members are placed tentatively
+// until the group has minMember members
+type podGroup struct {
+	name      string
+	minMember int
+	deadline  time.Time
+	// pod UID: the node of the tentative placement
+	members map[types.UID]string
+	// it's closed when the group has minMember members
+	ready   chan struct{}
+	readyAt time.Time
+}
+
+func (g *podGroup) isReady() bool {
+	return !g.readyAt.IsZero()
+}
+
+// BindGroupMember places the pod of a pod group on the node tentatively, and waits for
+// the other members of the group. The pods of the group are bound together when the
+// group has minMember members, or the tentative placement is released after the timeout.
+func (cache *SchedulerCache) BindGroupMember(clientset *kubernetes.Clientset, n *NodeInfo, pod *v1.Pod) error {
+	name, minMember := utils.GetPodGroupFromAnnotation(pod)
+	key := pod.Namespace + "/" + name
+
+	newPod, err := cache.Reserve(n, pod)
+	if err != nil {
+		return err
+	}
+
+	group := cache.joinPodGroup(key, minMember, pod.UID, n.name)
+	log.Printf("info: pod [%s] in namespace [%s] waits for the pod group [%s] on node [%s], %d of %d members are placed",
+		pod.Name,
+		pod.Namespace,
+		key,
+		n.name,
+		len(group.members),
+		group.minMember)
+
+	select {
+	case <-group.ready:
+	case <-time.After(time.Until(group.deadline)):
+		// the group may become ready at the timeout
+		placed, ready := cache.leavePodGroup(group, pod.UID)
+		if !ready {
+			n.Release(newPod)
+			return fmt.Errorf("pod group %s has %d of %d members placed in %v",
+				key,
+				placed,
+				minMember,
+				GetGangTimeout())
+		}
+	}
+
+	// the members are bound one by one, the ones bound stay bound if the others fail, and the failed
+	// member retried by the scheduler is bound at once while the group is ready
+	log.Printf("info: the pod group [%s] is ready, bind pod [%s] in namespace [%s] to node [%s]", key, pod.Name, pod.Namespace, n.name)
+	err = n.Commit(clientset, pod, newPod)
+	if err != nil {
+		log.Printf("warn: failed to bind pod [%s] in namespace [%s] of the ready pod group [%s], the other members are not rolled back: %v",
+			pod.Name,
+			pod.Namespace,
+			key,
+			err)
+	}
+	return err
+}
+
+// joinPodGroup adds the tentative placement of the pod to its group, the group is created
+// if it doesn't exist or it's expired
+func (cache *SchedulerCache) joinPodGroup(key string, minMember int, uid types.UID, nodeName string) *podGroup {
+	cache.gLock.Lock()
+	defer cache.gLock.Unlock()
+
+	now := time.Now()
+	cache.cleanPodGroups(now)
+	group, found := cache.groups[key]
+	if !found || (!group.isReady() && now.After(group.deadline)) {
+		group = &podGroup{
+			name:      key,
+			minMember: minMember,
+			deadline:  now.Add(GetGangTimeout()),
+			members:   map[types.UID]string{},
+			ready:     make(chan struct{}),
+		}
+		cache.groups[key] = group
+	}
+
+	group.members[uid] = nodeName
+	if !group.isReady() && len(group.members) >= group.minMember {
+		group.readyAt = now
+		close(group.ready)
+	}
+	return group
+}
+
+// leavePodGroup removes the tentative placement of the pod from the group which is not ready,
+// it returns the number of members placed in the group and if the group is ready
+func (cache *SchedulerCache) leavePodGroup(group *podGroup, uid types.UID) (placed int, ready bool) {
+	cache.gLock.Lock()
+	defer cache.gLock.Unlock()
+
+	placed = len(group.members)
+	if group.isReady() {
+		return placed, true
+	}
+	delete(group.members, uid)
+	if len(group.members) == 0 && cache.groups[group.name] == group {
+		delete(cache.groups, group.name)
+	}
+	return placed, false
+}
+
+// cleanPodGroups removes the groups which have been ready for the timeout, the members
+// coming later than that wait for a new group
+func (cache *SchedulerCache) cleanPodGroups(now time.Time) {
+	for key, group := range cache.groups {
+		if group.isReady() && now.Sub(group.readyAt) > GetGangTimeout() {
+			delete(cache.groups, key)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"fmt"
 	"log"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -10,6 +11,7 @@ import (
 	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -32,9 +34,9 @@ func NewNodeInfo(node *v1.Node) *NodeInfo {
 	log.Printf("debug: node creation with new node name for %s", node.Name)
 
 	devMap := map[int]*DeviceInfo{}
-	for i := 0; i < utils.GetGPUCountInNode(node); i++ {
-		// FIXME: now we assuming all devices in one node are the same
-		devMap[i] = newDeviceInfo(i, uint(utils.GetXPUSharesCapacity(node)/utils.GetGPUCountInNode(node)))
+	totalXPUCores := utils.GetXPUCoresCapacityByDev(node)
+	for i, totalXPUShares := range utils.GetXPUSharesCapacityByDev(node) {
+		devMap[i] = newDeviceInfo(i, totalXPUShares, totalXPUCores[i])
 	}
 
 	if len(devMap) == 0 {
@@ -51,8 +53,11 @@ func NewNodeInfo(node *v1.Node) *NodeInfo {
 	}
 }
 
-// Only update the devices when the length of devs is 0
+// Only create the devices when the length of devs is 0, otherwise update the capacity of the devices
 func (n *NodeInfo) Reset(node *v1.Node) {
+	n.rwmu.Lock()
+	defer n.rwmu.Unlock()
+
 	n.gpuCount = utils.GetGPUCountInNode(node)
 	n.gpuTotalMemory = utils.GetXPUSharesCapacity(node)
 	n.node = node
@@ -64,16 +69,70 @@ func (n *NodeInfo) Reset(node *v1.Node) {
 		log.Printf("warn: reset for node [%s] but the XPU shares is 0", node.Name)
 	}
 
+	totalXPUCores := utils.GetXPUCoresCapacityByDev(node)
 	if len(n.devs) == 0 && n.gpuCount > 0 {
 		devMap := map[int]*DeviceInfo{}
-		for i := 0; i < utils.GetGPUCountInNode(node); i++ {
-			devMap[i] = newDeviceInfo(i, uint(n.gpuTotalMemory/n.gpuCount))
+		for i, totalXPUShares := range utils.GetXPUSharesCapacityByDev(node) {
+			devMap[i] = newDeviceInfo(i, totalXPUShares, totalXPUCores[i])
 		}
 		n.devs = devMap
+	} else {
+		totalXPUShares := utils.GetXPUSharesCapacityByDev(node)
+		for i, shares := range totalXPUShares {
+			if dev, found := n.devs[i]; found {
+				dev.totalXPUShares = shares
+				dev.totalXPUCores = totalXPUCores[i]
+			} else {
+				n.devs[i] = newDeviceInfo(i, shares, totalXPUCores[i])
+			}
+		}
+		// the devices removed from the capacity of the node are gone
+		for i, dev := range n.devs {
+			if _, found := totalXPUShares[i]; !found {
+				log.Printf("warn: GPU[%d] is removed from node [%s] with pods %v", i, node.Name, dev.getActivePods())
+				delete(n.devs, i)
+			}
+		}
 	}
 	log.Printf("info: node reset update information for [%s] with devs %v", node.Name, n.devs)
 }
 
+// updateNode resets the devices if the resources of the node change, or keeps the annotations such as
+// the reserve and the overcommit ratio up to date, nothing is locked if the node is the same
+func (n *NodeInfo) updateNode(node *v1.Node) {
+	n.rwmu.RLock()
+	old := n.node
+	devCount := len(n.devs)
+	n.rwmu.RUnlock()
+	if old == node || (len(node.ResourceVersion) > 0 && old.ResourceVersion == node.ResourceVersion) {
+		log.Printf("debug: using the existing node [%s] with devices %d", n.name, devCount)
+		return
+	}
+
+	// if the existing node turn from non XPU shares to XPU shares
+	if devCount == 0 ||
+		utils.GetXPUSharesCapacity(old) <= 0 ||
+		utils.GetGPUCountInNode(old) <= 0 ||
+		utils.GetXPUSharesCapacity(old) != utils.GetXPUSharesCapacity(node) ||
+		utils.GetGPUCountInNode(old) != utils.GetGPUCountInNode(node) ||
+		utils.GetXPUCoresCapacity(old) != utils.GetXPUCoresCapacity(node) ||
+		old.Annotations[utils.EnvNodeCapacityByDevIndex] != node.Annotations[utils.EnvNodeCapacityByDevIndex] ||
+		old.Annotations[utils.EnvNodeCoresByDevIndex] != node.Annotations[utils.EnvNodeCoresByDevIndex] {
+		log.Printf("info: need update node [%s]", n.name)
+		n.Reset(node)
+		log.Printf("info: node: [%s], labels from cache after been updated: %v", node.Name, node.Labels)
+	} else {
+		log.Printf("info: using the existing node [%s] infor", n.name)
+		n.setNode(node)
+	}
+}
+
+func (n *NodeInfo) setNode(node *v1.Node) {
+	n.rwmu.Lock()
+	defer n.rwmu.Unlock()
+	n.node = node
+}
+
 func (n *NodeInfo) GetName() string {
 	return n.name
 }
@@ -81,7 +140,9 @@ func (n *NodeInfo) GetName() string {
 func (n *NodeInfo) GetDevs() []*DeviceInfo {
 	devs := make([]*DeviceInfo, n.gpuCount)
 	for i, dev := range n.devs {
-		devs[i] = dev
+		if i < len(devs) {
+			devs[i] = dev
+		}
 	}
 	return devs
 }
@@ -98,200 +159,826 @@ func (n *NodeInfo) GetGPUCount() int {
 	return n.gpuCount
 }
 
+func (n *NodeInfo) GetNodeTotalXPUCores() int {
+	return utils.GetXPUCoresCapacity(n.node)
+}
+
+// WithoutPods copies the node without the pods, as if they were evicted, the copy isn't in the cache
+func (n *NodeInfo) WithoutPods(uids []types.UID) *NodeInfo {
+	n.rwmu.RLock()
+	defer n.rwmu.RUnlock()
+
+	evicted := map[types.UID]bool{}
+	for _, uid := range uids {
+		evicted[uid] = true
+	}
+	copied := NewNodeInfo(n.node)
+	for id, dev := range n.devs {
+		copiedDev, found := copied.devs[id]
+		if !found {
+			continue
+		}
+		for _, p := range dev.getActivePods() {
+			if !evicted[p.UID] {
+				copiedDev.addPod(p)
+			}
+		}
+	}
+	return copied
+}
+
 func (n *NodeInfo) removePod(pod *v1.Pod) {
 	n.rwmu.Lock()
 	defer n.rwmu.Unlock()
 
-	id := utils.GetGPUIDFromAnnotation(pod)
-	if id >= 0 {
-		dev, found := n.devs[id]
-		if !found {
-			log.Printf("warn: pod [%s] in namespace [%s] failed to find the GPU[%d] in node [%s]", pod.Name, pod.Namespace, id, n.name)
-		} else {
-			dev.removePod(pod)
+	ids := utils.GetGPUIDsFromAnnotation(pod)
+	if len(ids) > 0 {
+		for _, id := range ids {
+			dev, found := n.devs[id]
+			if !found {
+				log.Printf("warn: pod [%s] in namespace [%s] failed to find the GPU[%d] in node [%s]", pod.Name, pod.Namespace, id, n.name)
+			} else {
+				dev.removePod(pod)
+			}
 		}
 	} else {
-		log.Printf("warn: pod [%s] in namespace [%s] is not set the GPU[%d] in node [%s]", pod.Name, pod.Namespace, id, n.name)
+		log.Printf("warn: pod [%s] in namespace [%s] is not set the GPU ID in node [%s]", pod.Name, pod.Namespace, n.name)
 	}
 }
 
-// Add the Pod which has the GPU id to the node
+// Add the Pod which has the GPU ids to the node
 func (n *NodeInfo) addOrUpdatePod(pod *v1.Pod) (added bool) {
 	n.rwmu.Lock()
 	defer n.rwmu.Unlock()
 
-	id := utils.GetGPUIDFromAnnotation(pod)
-	log.Printf("debug: pod [%s] in namespace [%s] with the GPU[%d] should be added to device map",
+	ids := utils.GetGPUIDsFromAnnotation(pod)
+	log.Printf("debug: pod [%s] in namespace [%s] with the GPU%v should be added to device map",
 		pod.Name,
 		pod.Namespace,
-		id)
-	if id >= 0 {
-		dev, found := n.devs[id]
-		if !found {
-			log.Printf("warn: pod [%s] in namespace [%s] failed to find the GPU[%d] in node [%s]", pod.Name, pod.Namespace, id, n.name)
-		} else {
-			dev.addPod(pod)
-			added = true
+		ids)
+	if len(ids) > 0 {
+		for _, id := range ids {
+			dev, found := n.devs[id]
+			if !found {
+				log.Printf("warn: pod [%s] in namespace [%s] failed to find the GPU[%d] in node [%s]", pod.Name, pod.Namespace, id, n.name)
+			} else {
+				dev.addPod(pod)
+				added = true
+			}
 		}
 	} else {
-		log.Printf("warn: pod [%s] in namespace [%s] is not set the GPU[%d] in node [%s]", pod.Name, pod.Namespace, id, n.name)
+		log.Printf("warn: pod [%s] in namespace [%s] is not set the GPU ID in node [%s]", pod.Name, pod.Namespace, n.name)
 	}
 	return added
 }
 
-// check if the pod can be allocated on the node
-func (n *NodeInfo) Assume(pod *v1.Pod) (allocatable bool) {
-	allocatable = false
+// check if the pod can be allocated on the node, the error tells why it can't
+func (n *NodeInfo) Assume(pod *v1.Pod) (allocatable bool, err error) {
+	n.rwmu.RLock()
+	defer n.rwmu.RUnlock()
+
+	_, err = n.allocateGPUID(pod)
+	return err == nil, err
+}
+
+// assumeXPUs gets the XPU shares of each device which the pod will be allocated on the node,
+// device index: XPU shares
+func (n *NodeInfo) assumeXPUs(pod *v1.Pod) (allocation map[int]uint, err error) {
+	n.rwmu.RLock()
+	defer n.rwmu.RUnlock()
+
+	containerAllocation, err := n.allocateGPUID(pod)
+	if err != nil {
+		return nil, err
+	}
+	return utils.GetXPUSharesByDevFromAllocation(containerAllocation), nil
+}
 
+// AssumeScore rates the placement of the pod on the node without allocating it, all are in [0, 1]:
+// tightness is how full the chosen devices will be, compactness is how much of the
+// free XPU shares of the node will stay usable for the recent requests, and coolness
+// is how many of the chosen devices are not hot by the telemetry
+func (n *NodeInfo) AssumeScore(pod *v1.Pod) (tightness float64, compactness float64, coolness float64, allocatable bool) {
 	n.rwmu.RLock()
 	defer n.rwmu.RUnlock()
 
-	availableXPUs := n.getAvailableXPUs()
-	reqXPUShares  := uint(utils.GetRequestXPUSharesFromPodResource(pod))
-	log.Printf("debug: all XPU Shares on this node: %v in node %s", availableXPUs, n.name)
-
-	if len(availableXPUs) > 0 {
-		for devID := 0; devID < len(n.devs); devID++ {
-			availableXPU, ok := availableXPUs[devID]
-			if ok {
-				if availableXPU >= reqXPUShares {
-					allocatable = true
-					break
-				}
+	containerAllocation, err := n.allocateGPUID(pod)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	allocation := utils.GetXPUSharesByDevFromAllocation(containerAllocation)
+
+	allXPUShares := n.getAllXPUs()
+	availableXPUShares := n.getAvailableXPUs()
+	var allocatedTotal, allocatedUsed uint
+	for id, shares := range allocation {
+		allocatedTotal += allXPUShares[id]
+		allocatedUsed += allXPUShares[id] - (availableXPUShares[id] - shares)
+	}
+	if allocatedTotal > 0 {
+		tightness = float64(allocatedUsed) / float64(allocatedTotal)
+	}
+
+	compactness = 1 - fragmentation(n.getFreeXPUShares(allocation), recentRequests.distribution())
+
+	coolness = 1
+	if hot := n.getHotDevices(); len(hot) > 0 && len(allocation) > 0 {
+		cool := 0
+		for id := range allocation {
+			if _, found := hot[id]; !found {
+				cool++
 			}
 		}
+		coolness = float64(cool) / float64(len(allocation))
 	}
 
-	return allocatable
-
+	return tightness, compactness, coolness, true
 }
 
-func (n *NodeInfo) Allocate(clientset *kubernetes.Clientset, pod *v1.Pod) (err error) {
-	var newPod *v1.Pod
+// Reserve allocates the XPU shares for the pod in the cache without updating the pod, the pod
+// returned is the tentative placement with the allocation annotations, which is counted by the
+// other pods until it's committed by Commit or released by Release, the elastic pod grows to at
+// most elasticLimit XPU shares, which is got by GetElasticLimit, 0 is unlimited
+func (n *NodeInfo) Reserve(pod *v1.Pod, elasticLimit uint) (newPod *v1.Pod, err error) {
 	n.rwmu.Lock()
 	defer n.rwmu.Unlock()
-	log.Printf("info: beginning to allocate XPU shares for pod [%s] in namespace [%s]", pod.Name, pod.Namespace)
-	// 1. update the pod spec
-	devId, found := n.allocateGPUID(pod)
-	if found {
-		log.Printf("info: GPU[%d] wil be allocated to pod [%s] in namespace [%s]", devId, pod.Name, pod.Namespace)
-		newPod = utils.GetUpdatedPodAnnotationSpec(pod, devId, n.GetNodeTotalGPUMemory()/n.GetGPUCount())
-		_, err = clientset.CoreV1().Pods(newPod.Namespace).Update(newPod)
-		if err != nil {
-			// the object has been modified; please apply your changes to the latest version and try again
-			if err.Error() == OptimisticLockErrorMsg {
-				// retry
-				pod, err = clientset.CoreV1().Pods(pod.Namespace).Get(pod.Name, metav1.GetOptions{})
-				if err != nil {
-					return err
-				}
-				newPod = utils.GetUpdatedPodAnnotationSpec(pod, devId, n.GetNodeTotalGPUMemory()/n.GetGPUCount())
-				_, err = clientset.CoreV1().Pods(newPod.Namespace).Update(newPod)
-				if err != nil {
-					return err
-				}
-			} else {
-				return err
-			}
+
+	allocation, err := n.allocateElasticGPUID(pod, elasticLimit)
+	if err != nil {
+		return nil, fmt.Errorf("the node %s can't place the pod [%s] in namespace [%s]: %v", n.name, pod.Name, pod.Namespace, err)
+	}
+
+	recentRequests.record(pod, allocation)
+	newPod = utils.GetUpdatedPodAnnotationSpec(pod, allocation, n.getPhysicalXPUs(), n.getAllXPUCores())
+	newPod.Spec.NodeName = n.name
+	for devId := range utils.GetXPUSharesByDevFromAllocation(allocation) {
+		if dev, found := n.devs[devId]; found {
+			dev.addPod(newPod)
 		}
-	} else {
-		err = fmt.Errorf("the node %s can't place the pod [%s] in namespace [%s]", pod.Spec.NodeName, pod.Name, pod.Namespace)
 	}
+	log.Printf("info: reserve GPU shares %v for pod [%s] in namespace [%s] on node [%s]", allocation, pod.Name, pod.Namespace, n.name)
+	return newPod, nil
+}
 
-	// 2. bind the pod to the node
+// Release removes the tentative placement of the pod made by Reserve
+func (n *NodeInfo) Release(newPod *v1.Pod) {
+	log.Printf("info: release the reserved GPU shares of pod [%s] in namespace [%s] on node [%s]", newPod.Name, newPod.Namespace, n.name)
+	n.removePod(newPod)
+}
+
+// Commit updates the pod with the tentative placement made by Reserve and binds it to the node,
+// the placement is released if it fails
+func (n *NodeInfo) Commit(clientset *kubernetes.Clientset, pod *v1.Pod, newPod *v1.Pod) (err error) {
+	n.rwmu.Lock()
+	allocation := utils.GetContainerAllocationFromAnnotation(newPod)
+	boundPod, err := n.updatePodAndBind(clientset, pod, allocation)
 	if err == nil {
-		binding := &v1.Binding{
-			ObjectMeta: metav1.ObjectMeta{Name: pod.Name, UID: pod.UID},
-			Target:     v1.ObjectReference{Kind: "Node", Name: n.name},
+		for devId := range utils.GetXPUSharesByDevFromAllocation(allocation) {
+			if dev, found := n.devs[devId]; found {
+				dev.addPod(boundPod)
+			}
 		}
-		log.Printf("info: trying to bind pod [%s] in [%s] namespace to node [%s]",
-			pod.Name,
-			pod.Namespace,
-			pod.Spec.NodeName)
-		err = clientset.CoreV1().Pods(pod.Namespace).Bind(binding)
+	}
+	n.rwmu.Unlock()
+
+	if err != nil {
+		n.Release(newPod)
+	}
+	return err
+}
+
+// updatePodAndBind writes the allocation to the pod annotations and binds the pod to the node
+func (n *NodeInfo) updatePodAndBind(clientset *kubernetes.Clientset, pod *v1.Pod, allocation []utils.ContainerAllocation) (newPod *v1.Pod, err error) {
+	newPod = utils.GetUpdatedPodAnnotationSpec(pod, allocation, n.getPhysicalXPUs(), n.getAllXPUCores())
+	_, err = clientset.CoreV1().Pods(newPod.Namespace).Update(newPod)
+	if err != nil {
+		// the object has been modified; please apply your changes to the latest version and try again
+		if err.Error() == OptimisticLockErrorMsg {
+			// retry
+			pod, err = clientset.CoreV1().Pods(pod.Namespace).Get(pod.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			newPod = utils.GetUpdatedPodAnnotationSpec(pod, allocation, n.getPhysicalXPUs(), n.getAllXPUCores())
+			_, err = clientset.CoreV1().Pods(newPod.Namespace).Update(newPod)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	binding := &v1.Binding{
+		ObjectMeta: metav1.ObjectMeta{Name: pod.Name, UID: pod.UID},
+		Target:     v1.ObjectReference{Kind: "Node", Name: n.name},
+	}
+	log.Printf("info: trying to bind pod [%s] in [%s] namespace to node [%s]",
+		pod.Name,
+		pod.Namespace,
+		n.name)
+	err = clientset.CoreV1().Pods(pod.Namespace).Bind(binding)
+	if err != nil {
+		log.Printf("warn: failed to bind the pod [%s] in namespace [%s] due to %v", pod.Name, pod.Namespace, err)
+		return nil, err
+	}
+	return newPod, nil
+}
+
+// allocate the GPU IDs to each container of the pod, every container requesting XPU shares
+// is placed independently, and the shares taken by the former containers are not available.
+// The error tells why the pod can't be placed.
+func (n *NodeInfo) allocateGPUID(pod *v1.Pod) (allocation []utils.ContainerAllocation, err error) {
+	return n.allocateElasticGPUID(pod, 0)
+}
+
+// allocateElasticGPUID allocates the GPU IDs in the same way as allocateGPUID, and the elastic
+// container grows to at most elasticLimit XPU shares besides its maximum, 0 is unlimited
+func (n *NodeInfo) allocateElasticGPUID(pod *v1.Pod, elasticLimit uint) (allocation []utils.ContainerAllocation, err error) {
+
+	reqShares          := uint(0)
+	strategy           := getStrategy(pod)
+	availableXPUCores  := n.getAvailableXPUCores()
+	availableXPUShares, excluded := n.getAvailableXPUsForPod(pod)
+	allocation = []utils.ContainerAllocation{}
+
+	reqShares = uint(utils.GetRequestXPUSharesFromPodResource(pod))
+	if reqCounts := utils.GetRequestXPUCountsFromPodResource(pod); reqCounts > 0 {
+		if reqShares > 0 || utils.GetRequestXPUCoresFromPodResource(pod) > 0 {
+			log.Printf("warn: pod [%s] in namespace [%s] requests both XPU shares and whole devices, it's not supported",
+				pod.Name,
+				pod.Namespace)
+			return allocation, fmt.Errorf("requesting both XPU shares and whole devices is not supported")
+		}
+		return n.allocateWholeDevices(pod, reqCounts, availableXPUShares, excluded)
+	}
+
+	if reqShares == uint(0) {
+		return allocation, fmt.Errorf("no XPU shares requested")
+	}
+
+	log.Printf("info: request XPU shares for pod [%s] in namespace [%s]: [%d] with strategy [%s]", pod.Name, pod.Namespace, reqShares, strategy.Name())
+	log.Printf("info: available XPU shares: %v and cores: %v in node [%s]", availableXPUShares, availableXPUCores, n.name)
+
+	// the containers are placed on the devices where the largest init container fits first, so the
+	// init containers can run on the devices of the containers
+	var maxInitShares uint
+	for _, container := range pod.Spec.InitContainers {
+		if shares := uint(utils.GetRequestXPUSharesFromContainerResource(container)); shares > maxInitShares {
+			maxInitShares = shares
+		}
+	}
+	if maxInitShares > 0 {
+		fitXPUShares := map[int]uint{}
+		fitXPUCores := map[int]uint{}
+		for id, shares := range availableXPUShares {
+			if shares >= maxInitShares {
+				fitXPUShares[id] = shares
+				fitXPUCores[id] = availableXPUCores[id]
+			}
+		}
+		if len(fitXPUShares) > 0 && len(fitXPUShares) < len(availableXPUShares) {
+			allocation, err = n.allocateContainers(pod, strategy, fitXPUShares, fitXPUCores, excluded, elasticLimit)
+			if err == nil {
+				return allocation, nil
+			}
+		}
+	}
+	return n.allocateContainers(pod, strategy, availableXPUShares, availableXPUCores, excluded, elasticLimit)
+}
+
+// allocateContainers allocates the available XPU shares and cores to the containers and the init containers
+// of the pod, the elastic container grows to at most elasticLimit XPU shares besides its maximum, 0 is unlimited
+func (n *NodeInfo) allocateContainers(pod *v1.Pod, strategy Strategy, availableXPUShares map[int]uint, availableXPUCores map[int]uint,
+	excluded deviceReasons, elasticLimit uint) (allocation []utils.ContainerAllocation, err error) {
+	allocation = []utils.ContainerAllocation{}
+	initXPUShares := map[int]uint{}
+	for id, shares := range availableXPUShares {
+		initXPUShares[id] = shares
+	}
+	initXPUCores := map[int]uint{}
+	for id, cores := range availableXPUCores {
+		initXPUCores[id] = cores
+	}
+	elastic, minShares, maxShares := utils.GetElasticXPUSharesFromAnnotation(pod)
+	if elasticLimit > 0 && elasticLimit < maxShares {
+		maxShares = elasticLimit
+	}
+	for _, container := range pod.Spec.Containers {
+		containerShares := uint(utils.GetRequestXPUSharesFromContainerResource(container))
+		containerCores := uint(utils.GetRequestXPUCoresFromContainerResource(container))
+		if container.Name == elastic {
+			containerShares = minShares
+		}
+		if containerShares == 0 {
+			if containerCores > 0 {
+				log.Printf("warn: container [%s] of pod [%s] in namespace [%s] requests XPU cores without XPU shares, skip",
+					container.Name,
+					pod.Name,
+					pod.Namespace)
+			}
+			continue
+		}
+
+		shares, cores, err := n.allocateXPUShares(pod, strategy, containerShares, containerCores, availableXPUShares, availableXPUCores, excluded)
 		if err != nil {
-			log.Printf("warn: failed to bind the pod [%s] in namespace [%s] due to %v", pod.Name, pod.Namespace, err)
-			return err
+			log.Printf("warn: failed to find available XPU shares [%d] and cores [%d] for the container [%s] of pod [%s] in the namespace [%s]: %v",
+				containerShares,
+				containerCores,
+				container.Name,
+				pod.Name,
+				pod.Namespace,
+				err)
+			return []utils.ContainerAllocation{}, fmt.Errorf("container %s: %v", container.Name, err)
+		}
+		if container.Name == elastic {
+			containerShares = growXPUShares(shares, maxShares, availableXPUShares, n.getPhysicalXPUs())
 		}
+
+		for id := range shares {
+			availableXPUShares[id] -= shares[id]
+			availableXPUCores[id] -= cores[id]
+		}
+		containerAllocation := utils.ContainerAllocation{
+			Name:    container.Name,
+			Devices: shares,
+			Burst:   n.getBurst(shares, containerShares, uint(utils.GetLimitXPUSharesFromContainerResource(pod, container))),
+		}
+		if containerCores > 0 {
+			containerAllocation.Cores = cores
+		}
+		allocation = append(allocation, containerAllocation)
 	}
 
-	// 3. update the device info if the pod is update successfully
-	if err == nil {
-		log.Printf("info: trying to add pod [%s] in namespace [%s] to dev [%d]",
-			pod.Name,
-			pod.Namespace,
-			devId)
-		dev, found := n.devs[devId]
-		if !found {
-			log.Printf("warn: pod [%s] in namespace [%s] failed to find the GPU[%d] in node [%s]", pod.Name, pod.Namespace, devId, n.name)
+	// the init containers run one by one before the containers, so each of them can use the XPU
+	// shares available to the pod, and the pod takes the most of them on each device. They are only
+	// placed on the devices of the pod, otherwise the pod would hold the XPU shares of the other devices
+	// all its life, so the largest one is placed first if the containers don't request XPU shares.
+	initContainers := append([]v1.Container{}, pod.Spec.InitContainers...)
+	if len(allocation) == 0 {
+		sort.SliceStable(initContainers, func(i, j int) bool {
+			return utils.GetRequestXPUSharesFromContainerResource(initContainers[i]) > utils.GetRequestXPUSharesFromContainerResource(initContainers[j])
+		})
+	}
+	for _, container := range initContainers {
+		containerShares := uint(utils.GetRequestXPUSharesFromContainerResource(container))
+		containerCores := uint(utils.GetRequestXPUCoresFromContainerResource(container))
+		if containerShares == 0 {
+			continue
+		}
+
+		podXPUShares := map[int]uint{}
+		podXPUCores := map[int]uint{}
+		for id := range utils.GetXPUSharesByDevFromAllocation(allocation) {
+			podXPUShares[id] = initXPUShares[id]
+			podXPUCores[id] = initXPUCores[id]
+		}
+		var shares, cores map[int]uint
+		if len(podXPUShares) > 0 {
+			shares, cores, err = n.allocateXPUShares(pod, strategy, containerShares, containerCores, podXPUShares, podXPUCores, excluded)
+			if err != nil {
+				err = fmt.Errorf("not in the devices of the pod: %v", err)
+			}
 		} else {
-			dev.addPod(newPod)
+			shares, cores, err = n.allocateXPUShares(pod, strategy, containerShares, containerCores, initXPUShares, initXPUCores, excluded)
+		}
+		if err != nil {
+			log.Printf("warn: failed to find available XPU shares [%d] and cores [%d] for the init container [%s] of pod [%s] in the namespace [%s]: %v",
+				containerShares,
+				containerCores,
+				container.Name,
+				pod.Name,
+				pod.Namespace,
+				err)
+			return []utils.ContainerAllocation{}, fmt.Errorf("init container %s: %v", container.Name, err)
 		}
+
+		containerAllocation := utils.ContainerAllocation{
+			Name:    container.Name,
+			Devices: shares,
+			Burst:   n.getBurst(shares, containerShares, uint(utils.GetLimitXPUSharesFromContainerResource(pod, container))),
+			Init:    true,
+		}
+		if containerCores > 0 {
+			containerAllocation.Cores = cores
+		}
+		allocation = append(allocation, containerAllocation)
 	}
-	log.Printf("info: ending to allocate XPU shares for pod [%s] in namespace [%s]", pod.Name, pod.Namespace)
-	return err
+
+	return allocation, nil
 }
 
-// allocate the GPU ID to the pod
-func (n *NodeInfo) allocateGPUID(pod *v1.Pod) (candidateDevID int, found bool) {
+// growXPUShares grows the XPU shares of the elastic container in one device to the maximum, or to all the
+// available XPU shares of the device if they are less, and never beyond the physical capacity of the device
+// even if it's overcommitted, and returns the granted XPU shares
+func growXPUShares(shares map[int]uint, maxShares uint, availableXPUShares map[int]uint, physicalXPUShares map[int]uint) (granted uint) {
+	if len(shares) != 1 {
+		for _, s := range shares {
+			granted += s
+		}
+		return granted
+	}
+	for id, s := range shares {
+		granted = maxShares
+		if availableXPUShares[id] < granted {
+			granted = availableXPUShares[id]
+		}
+		if physicalXPUShares[id] < granted {
+			granted = physicalXPUShares[id]
+		}
+		if granted < s {
+			granted = s
+		}
+		shares[id] = granted
+	}
+	return granted
+}
 
-	reqShares      := uint(0)
-	found          = false
-	candidateDevID = -1
-	candidateXPUShares := uint(0)
-	availableXPUShares := n.getAvailableXPUs()
-	availableXPUCount  := uint(0)
-	allocatedXPUShares := map[int]uint{}
+// getBurst scales the XPU shares of the container on each device to its limit, which is not more than
+// the capacity of the device, it's nil if the container can't burst
+func (n *NodeInfo) getBurst(shares map[int]uint, reqShares uint, limitShares uint) (burst map[int]uint) {
+	if limitShares <= reqShares {
+		return nil
+	}
+	physicalXPUShares := n.getPhysicalXPUs()
+	burst = map[int]uint{}
+	for id, s := range shares {
+		burst[id] = s * limitShares / reqShares
+		if burst[id] > physicalXPUShares[id] {
+			burst[id] = physicalXPUShares[id]
+		}
+	}
+	return burst
+}
 
-	reqShares = uint(utils.GetRequestXPUSharesFromPodResource(pod))
+// allocateXPUShares chooses the devices for the request from the available XPU shares and cores,
+// device index: XPU shares and device index: XPU cores, the error tells why each device is not chosen
+func (n *NodeInfo) allocateXPUShares(pod *v1.Pod, strategy Strategy, reqShares uint, reqCores uint,
+	availableXPUShares map[int]uint, availableXPUCores map[int]uint, excluded deviceReasons) (shares map[int]uint, cores map[int]uint, err error) {
+	candidateDevID := -1
+	candidateScore := 0
+	reasons := deviceReasons{}
+	avoided := n.getAvoidedDevices(pod)
+	physicalXPUShares := n.getPhysicalXPUs()
+
+	for devID := 0; devID < len(n.devs); devID++ {
+		if reason, found := excluded[devID]; found {
+			reasons[devID] = reason
+			continue
+		}
+		availableShares, ok := availableXPUShares[devID]
+		if !ok {
+			reasons[devID] = "unavailable"
+			continue
+		}
+		// the overcommit ratio lets the pods share more than the capacity, but one request can't be more
+		if reqShares > physicalXPUShares[devID] {
+			reasons[devID] = fmt.Sprintf("more XPU shares than the capacity (%d > %d)", reqShares, physicalXPUShares[devID])
+			continue
+		}
+		if availableShares < reqShares {
+			reasons[devID] = fmt.Sprintf("insufficient XPU shares (%d < %d)", availableShares, reqShares)
+			continue
+		}
+		if reqCores > 0 && availableXPUCores[devID] < reqCores {
+			reasons[devID] = fmt.Sprintf("insufficient XPU cores (%d < %d)", availableXPUCores[devID], reqCores)
+			continue
+		}
 
-	if reqShares > uint(0) {
-		log.Printf("info: request XPU shares for pod [%s] in namespace [%s]: [%d]", pod.Name, pod.Namespace, reqShares)
-		log.Printf("info: available XPU shares: %v in node [%s]", availableXPUShares, n.name)
-		if len(availableXPUShares) > 0 {
-			for devID := 0; devID < len(n.devs); devID++ {
-				availableShares, ok := availableXPUShares[devID]
-				availableXPUCount += availableShares
-				if ok {
-					if availableShares >= reqShares {
-						if candidateDevID == -1 || candidateXPUShares > availableShares {
-							candidateDevID = devID
-							candidateXPUShares = availableShares
-						}
-						// first we found one device is enough for request
-						found = true
-						log.Printf("info: find candidate GPU[%d] for pod [%s] in namespace [%s] successfully.",
-							candidateDevID,
-							pod.Name,
-							pod.Namespace)
-					}
-				}
+		// the device without the PreferNoSchedule taints wins whatever the score is
+		score := strategy.Score(devID, availableShares, reqShares)
+		if candidateDevID == -1 ||
+			(avoided[candidateDevID] && !avoided[devID]) ||
+			(avoided[candidateDevID] == avoided[devID] && score > candidateScore) {
+			candidateDevID = devID
+			candidateScore = score
+		}
+		log.Printf("info: find candidate GPU[%d] for pod [%s] in namespace [%s] successfully.",
+			candidateDevID,
+			pod.Name,
+			pod.Namespace)
+	}
+
+	if candidateDevID >= 0 {
+		return map[int]uint{candidateDevID: reqShares}, map[int]uint{candidateDevID: reqCores}, nil
+	}
+
+	if utils.IsMultiDeviceRequest(pod) {
+		// separate shares to different devices, the strategy is not used here
+		// because the fewest devices with the fastest links are always preferred
+		var found bool
+		splittable := map[int]uint{}
+		for id, s := range availableXPUShares {
+			if s > physicalXPUShares[id] {
+				s = physicalXPUShares[id]
 			}
+			splittable[id] = s
+		}
+		shares, found = n.splitXPUShares(reqShares, splittable)
+		if found {
+			cores, found = splitXPUCores(reqCores, reqShares, shares, availableXPUCores)
 			if !found {
-				// FIMXE: should separate shares to different devices
-				if (availableXPUCount >= reqShares) {
-					for devID := 0; devID < len(n.devs); devID++ {
-						availableShares, ok := availableXPUShares[devID]
-						if ok {
-							if (availableXPUCount > 0) {
-								allocatedXPUShares[devID] = availableShares;
-							}
-						}
-					}
-				}
+				return nil, nil, fmt.Errorf("insufficient XPU cores in the devices %v for separated XPU shares", shares)
 			}
-		}
-		
-		
-		if !found  {
-			log.Printf("warn: failed to find available XPU shares [%d] for the pod [%s] in the namespace [%s]",
-				reqShares,
+			log.Printf("info: separate XPU shares %v and cores %v for pod [%s] in namespace [%s] successfully.",
+				shares,
+				cores,
 				pod.Name,
 				pod.Namespace)
+			return shares, cores, nil
+		}
+		return nil, nil, fmt.Errorf("insufficient XPU shares in all devices: %v", reasons)
+	}
+
+	return nil, nil, fmt.Errorf("insufficient XPU in one device: %v", reasons)
+}
+
+// splitXPUCores separates the cores in the same proportion as the separated shares, the last
+// device takes the remainder
+func splitXPUCores(reqCores uint, reqShares uint, shares map[int]uint, availableXPUCores map[int]uint) (cores map[int]uint, found bool) {
+	cores = map[int]uint{}
+	ids := []int{}
+	for id := range shares {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	remaining := reqCores
+	for i, id := range ids {
+		devCores := reqCores * shares[id] / reqShares
+		if i == len(ids)-1 {
+			devCores = remaining
+		}
+		if devCores > availableXPUCores[id] {
+			return nil, false
+		}
+		cores[id] = devCores
+		remaining -= devCores
+	}
+	return cores, true
+}
+
+// deviceReasons records why each device is not chosen, device index: reason
+type deviceReasons map[int]string
+
+func (r deviceReasons) String() string {
+	ids := []int{}
+	for id := range r {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	reasons := []string{}
+	for _, id := range ids {
+		reasons = append(reasons, fmt.Sprintf("GPU[%d] %s", id, r[id]))
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// allocateWholeDevices chooses the devices without any pod for the pod requesting whole devices,
+// all the XPU shares of the chosen devices are allocated to the pod, and each container gets
+// the number of devices it requests
+func (n *NodeInfo) allocateWholeDevices(pod *v1.Pod, reqCounts int, availableXPUShares map[int]uint, excluded deviceReasons) (allocation []utils.ContainerAllocation, err error) {
+	allocation = []utils.ContainerAllocation{}
+	allXPUShares := n.getAllXPUs()
+	physicalXPUShares := n.getPhysicalXPUs()
+	allXPUCores := n.getAllXPUCores()
+	reservedXPUShares := n.getReservedXPUs()
+	log.Printf("info: request %d whole devices for pod [%s] in namespace [%s]", reqCounts, pod.Name, pod.Namespace)
+
+	freeDevIDs := []int{}
+	for devID := 0; devID < len(n.devs); devID++ {
+		availableShares, ok := availableXPUShares[devID]
+		if ok && availableShares > 0 && availableShares+reservedXPUShares[devID] >= allXPUShares[devID] {
+			freeDevIDs = append(freeDevIDs, devID)
+		}
+	}
+
+	if len(freeDevIDs) < reqCounts {
+		log.Printf("warn: failed to find %d free devices for the pod [%s] in the namespace [%s], only %d are free",
+			reqCounts,
+			pod.Name,
+			pod.Namespace,
+			len(freeDevIDs))
+		if len(excluded) > 0 {
+			return allocation, fmt.Errorf("insufficient free devices (%d < %d): %v", len(freeDevIDs), reqCounts, excluded)
+		}
+		return allocation, fmt.Errorf("insufficient free devices (%d < %d)", len(freeDevIDs), reqCounts)
+	}
+
+	// the devices with the PreferNoSchedule taints are chosen only if the others are not enough
+	avoided := n.getAvoidedDevices(pod)
+	preferredDevIDs := []int{}
+	for _, devID := range freeDevIDs {
+		if !avoided[devID] {
+			preferredDevIDs = append(preferredDevIDs, devID)
+		}
+	}
+	if len(preferredDevIDs) >= reqCounts {
+		freeDevIDs = preferredDevIDs
+	}
+
+	topology := utils.GetDeviceTopology(n.node)
+	chosenDevIDs := []int{}
+	chosenBandwidth := -1
+	for _, seed := range freeDevIDs {
+		ids := selectConnectedDevices(topology, freeDevIDs, []int{seed}, func(ids []int) bool {
+			return len(ids) >= reqCounts
+		})
+		if bandwidth := getTopologyScore(topology, ids); bandwidth > chosenBandwidth {
+			chosenDevIDs = ids
+			chosenBandwidth = bandwidth
+		}
+	}
+	log.Printf("info: choose devices %v with link bandwidth %d for pod [%s] in namespace [%s]",
+		chosenDevIDs,
+		chosenBandwidth,
+		pod.Name,
+		pod.Namespace)
+
+	wholeDevices := func(devIDs []int) (devices map[int]uint, cores map[int]uint) {
+		devices = map[int]uint{}
+		cores = map[int]uint{}
+		for _, devID := range devIDs {
+			// the whole device isn't overcommitted
+			devices[devID] = physicalXPUShares[devID]
+			if reservedXPUShares[devID] < devices[devID] {
+				devices[devID] -= reservedXPUShares[devID]
+			}
+			if allXPUCores[devID] > 0 {
+				cores[devID] = allXPUCores[devID]
+			}
+		}
+		return devices, cores
+	}
+
+	// the init containers run one by one before the containers, so they take the devices of the pod again
+	podDevIDs := chosenDevIDs
+	for _, container := range pod.Spec.Containers {
+		containerCounts := utils.GetRequestXPUCountsFromContainerResource(container)
+		if containerCounts == 0 {
+			continue
+		}
+		devices, cores := wholeDevices(chosenDevIDs[:containerCounts])
+		chosenDevIDs = chosenDevIDs[containerCounts:]
+		allocation = append(allocation, utils.ContainerAllocation{
+			Name:    container.Name,
+			Devices: devices,
+			Cores:   cores,
+		})
+	}
+	for _, container := range pod.Spec.InitContainers {
+		containerCounts := utils.GetRequestXPUCountsFromContainerResource(container)
+		if containerCounts == 0 {
+			continue
+		}
+		devices, cores := wholeDevices(podDevIDs[:containerCounts])
+		allocation = append(allocation, utils.ContainerAllocation{
+			Name:    container.Name,
+			Devices: devices,
+			Cores:   cores,
+			Init:    true,
+		})
+	}
+	return allocation, nil
+}
+
+// splitXPUShares separates the request to as few devices as possible, and the devices with
+// the most link bandwidth between them are preferred if the topology of the node is published
+func (n *NodeInfo) splitXPUShares(reqShares uint, availableXPUShares map[int]uint) (allocation map[int]uint, found bool) {
+	ids := []int{}
+	for id, availableShares := range availableXPUShares {
+		if availableShares > 0 {
+			ids = append(ids, id)
+		}
+	}
+	// the devices with the most available shares come first
+	sort.Slice(ids, func(i, j int) bool {
+		if availableXPUShares[ids[i]] == availableXPUShares[ids[j]] {
+			return ids[i] < ids[j]
+		}
+		return availableXPUShares[ids[i]] > availableXPUShares[ids[j]]
+	})
+
+	topology := utils.GetDeviceTopology(n.node)
+	enough := func(ids []int) bool {
+		var shares uint
+		for _, id := range ids {
+			shares += availableXPUShares[id]
+		}
+		return shares >= reqShares
+	}
+	chosenIDs := []int{}
+	chosenBandwidth := -1
+	for _, seed := range ids {
+		candidateIDs := selectConnectedDevices(topology, ids, []int{seed}, enough)
+		if !enough(candidateIDs) {
+			continue
+		}
+		bandwidth := getTopologyScore(topology, candidateIDs)
+		if len(chosenIDs) == 0 || len(candidateIDs) < len(chosenIDs) ||
+			(len(candidateIDs) == len(chosenIDs) && bandwidth > chosenBandwidth) {
+			chosenIDs = candidateIDs
+			chosenBandwidth = bandwidth
+		}
+	}
+
+	if len(chosenIDs) == 0 {
+		return map[int]uint{}, false
+	}
+
+	allocation = map[int]uint{}
+	remaining := reqShares
+	for _, id := range chosenIDs {
+		shares := availableXPUShares[id]
+		if shares > remaining {
+			shares = remaining
+		}
+		allocation[id] = shares
+		remaining -= shares
+	}
+	return allocation, true
+}
+
+// linkBandwidths are the relative bandwidth of the PCIe link types between two devices,
+// NVLink is scored by linkBandwidth from the number of links
+var linkBandwidths = map[string]int{
+	"PIX":  10,
+	"PXB":  8,
+	"PHB":  6,
+	"NODE": 4,
+	"SYS":  2,
+}
+
+// linkBandwidth gets the relative bandwidth of the link type, e.g. NV2 is two NVLinks
+func linkBandwidth(linkType string) int {
+	linkType = strings.ToUpper(strings.TrimSpace(linkType))
+	if strings.HasPrefix(linkType, "NV") {
+		links, err := strconv.Atoi(strings.TrimPrefix(linkType, "NV"))
+		if err != nil || links <= 0 {
+			links = 1
 		}
+		return 25 * links
 	}
+	return linkBandwidths[linkType]
+}
 
-	return candidateDevID, found
+// getTopologyScore is the aggregate link bandwidth between every two of the devices, it's 0 without topology
+func getTopologyScore(topology [][]string, ids []int) (bandwidth int) {
+	if topology == nil {
+		return 0
+	}
+	for i := 0; i < len(ids); i++ {
+		for j := i + 1; j < len(ids); j++ {
+			if ids[i] < len(topology) && ids[j] < len(topology) {
+				bandwidth += linkBandwidth(topology[ids[i]][ids[j]])
+			}
+		}
+	}
+	return bandwidth
+}
+
+// selectConnectedDevices adds the candidate with the most link bandwidth to the chosen devices one by one
+// until enough, the earlier candidate wins the tie
+func selectConnectedDevices(topology [][]string, candidates []int, chosen []int, enough func(ids []int) bool) []int {
+	used := map[int]bool{}
+	for _, id := range chosen {
+		used[id] = true
+	}
+
+	for !enough(chosen) {
+		next := -1
+		nextBandwidth := -1
+		for _, id := range candidates {
+			if used[id] {
+				continue
+			}
+			if bandwidth := getTopologyScore(topology, append([]int{id}, chosen...)); bandwidth > nextBandwidth {
+				next = id
+				nextBandwidth = bandwidth
+			}
+		}
+		if next == -1 {
+			break
+		}
+		chosen = append(chosen, next)
+		used[next] = true
+	}
+	return chosen
+}
+
+// getAvailableXPUsForPod gets the XPU shares of each device available to the pod, the devices which the pod
+// can't use are excluded with the reasons, and the XPU shares held for the other pods are hidden
+func (n *NodeInfo) getAvailableXPUsForPod(pod *v1.Pod) (availableXPUShares map[int]uint, excluded deviceReasons) {
+	availableXPUShares = n.getAvailableXPUs()
+	excluded = n.getExcludedDevices(pod)
+	for id := range excluded {
+		delete(availableXPUShares, id)
+	}
+	n.limitByNamespaceQuota(pod, availableXPUShares, excluded)
+	n.hideReservations(pod, availableXPUShares, excluded)
+	n.hideFromLowPriority(pod, availableXPUShares, excluded)
+	return availableXPUShares, excluded
 }
 
 func (n *NodeInfo) getAvailableXPUs() (availableXPUShares map[int]uint) {
@@ -301,7 +988,21 @@ func (n *NodeInfo) getAvailableXPUs() (availableXPUShares map[int]uint) {
 	availableXPUShares  = map[int]uint{}
 	for id, totalShares := range allXPUShares {
 		if usedShares, found := usedXPUShares[id]; found {
-			availableXPUShares[id] = totalShares - usedShares
+			if totalShares > usedShares {
+				availableXPUShares[id] = totalShares - usedShares
+			} else {
+				availableXPUShares[id] = 0
+			}
+		}
+	}
+	reservedXPUShares := n.getReservedXPUs()
+	for id, reserved := range reservedXPUShares {
+		if availableShares, found := availableXPUShares[id]; found && reserved > 0 {
+			if availableShares > reserved {
+				availableXPUShares[id] = availableShares - reserved
+			} else {
+				availableXPUShares[id] = 0
+			}
 		}
 	}
 	log.Printf("info: available XPU shares list %v before removing unhealty XPU shares", availableXPUShares)
@@ -310,6 +1011,16 @@ func (n *NodeInfo) getAvailableXPUs() (availableXPUShares map[int]uint) {
 		delete(availableXPUShares, id)
 	}
 	log.Printf("info: available XPU shares list %v after removing unhealty XPU shares", availableXPUShares)
+	for id := range n.getUnallocatableXPUs(unhealthyXPUShares) {
+		log.Printf("info: delete dev %d out of the allocatable resources of node [%s] from availble XPU shares list", id, n.name)
+		delete(availableXPUShares, id)
+	}
+	for id, dev := range n.devs {
+		if owner := dev.GetOwner(); owner != nil {
+			log.Printf("info: delete dev %d owned by pod [%s] in namespace [%s] from availble XPU shares list", id, owner.Name, owner.Namespace)
+			delete(availableXPUShares, id)
+		}
+	}
 
 	return availableXPUShares
 }
@@ -324,16 +1035,87 @@ func (n *NodeInfo) getUsedXPUs() (usedXPUShares map[int]uint) {
 	return usedXPUShares
 }
 
-// device index: XPU shares
+// device index: XPU shares, it's the effective capacity with the overcommit ratio
 func (n *NodeInfo) getAllXPUs() (allXPUShares map[int]uint) {
 	allXPUShares = map[int]uint{}
+	ratios := n.getOvercommitRatios()
 	for _, dev := range n.devs {
-		allXPUShares[dev.idx] = dev.totalXPUShares
+		allXPUShares[dev.idx] = overcommit(dev.totalXPUShares, ratios[dev.idx])
 	}
 	log.Printf("info: all XPU shares: %v in node [%s], and dev %v", allXPUShares, n.name, n.devs)
 	return allXPUShares
 }
 
+// device index: XPU shares, it's the physical capacity written to the pod annotations, so the device
+// plugin and the runtime get the fraction of the device allocated to the pod without the overcommit ratio
+func (n *NodeInfo) getPhysicalXPUs() (physicalXPUShares map[int]uint) {
+	physicalXPUShares = map[int]uint{}
+	for _, dev := range n.devs {
+		physicalXPUShares[dev.idx] = dev.totalXPUShares
+	}
+	return physicalXPUShares
+}
+
+// device index: XPU cores, the devices are the same as the available XPU shares
+func (n *NodeInfo) getAvailableXPUCores() (availableXPUCores map[int]uint) {
+	availableXPUCores = map[int]uint{}
+	for _, dev := range n.devs {
+		totalCores := dev.GetDevTotalXPUCores()
+		usedCores := dev.GetDevUsedXPUCores()
+		if totalCores > usedCores {
+			availableXPUCores[dev.idx] = totalCores - usedCores
+		} else {
+			availableXPUCores[dev.idx] = 0
+		}
+	}
+	return availableXPUCores
+}
+
+// device index: XPU cores
+func (n *NodeInfo) getAllXPUCores() (allXPUCores map[int]uint) {
+	allXPUCores = map[int]uint{}
+	for _, dev := range n.devs {
+		allXPUCores[dev.idx] = dev.totalXPUCores
+	}
+	return allXPUCores
+}
+
+// getUnallocatableXPUs gets the devices which are out of the allocatable resources of the node. The device
+// plugin removes the unhealthy devices from the allocatable resources without telling which ones, so the
+// unhealthy devices in the configmap count first, then the empty devices from the highest index are taken
+// until the capacity out of the allocatable resources is covered, and the pods running are not affected.
+func (n *NodeInfo) getUnallocatableXPUs(unhealthyGPUs map[int]bool) (unallocatable map[int]bool) {
+	unallocatable = map[int]bool{}
+	missingCounts := utils.GetGPUCountInNode(n.node) - utils.GetGPUCountAllocatable(n.node)
+	missingShares := utils.GetXPUSharesCapacity(n.node) - utils.GetXPUSharesAllocatable(n.node)
+	if missingCounts <= 0 && missingShares <= 0 {
+		return unallocatable
+	}
+
+	covered := func(id int) {
+		missingCounts--
+		missingShares -= int(n.devs[id].totalXPUShares)
+	}
+	for id := range unhealthyGPUs {
+		if _, found := n.devs[id]; found {
+			covered(id)
+		}
+	}
+	for id := len(n.devs) - 1; id >= 0 && (missingCounts > 0 || missingShares > 0); id-- {
+		dev, found := n.devs[id]
+		if !found || unhealthyGPUs[id] || len(dev.getActivePods()) > 0 {
+			continue
+		}
+		unallocatable[id] = true
+		covered(id)
+	}
+	if missingCounts > 0 || missingShares > 0 {
+		log.Printf("warn: node [%s] has fewer allocatable devices than its capacity, but not enough empty devices to hold them back, "+
+			"name the unhealthy devices in the configmap unhealthy-gpu-[%s]", n.name, n.name)
+	}
+	return unallocatable
+}
+
 // getUnhealthyXPUs get the unhealthy GPUs from configmap
 func (n *NodeInfo) getUnhealthyXPUs() (unhealthyGPUs map[int]bool) {
 	unhealthyGPUs = map[int]bool{}
@@ -0,0 +1,277 @@
+package cache
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// setTestConfigMaps makes the configmaps in kube-system the only ones the cache reads
+func setTestConfigMaps(t *testing.T, configMaps ...*v1.ConfigMap) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, cm := range configMaps {
+		cm.Namespace = metav1.NamespaceSystem
+		if err := indexer.Add(cm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ConfigMapLister = corelisters.NewConfigMapLister(indexer)
+}
+
+func newTestNode(count, shares int, annotations map[string]string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1", Annotations: annotations},
+		Status: v1.NodeStatus{Capacity: v1.ResourceList{
+			utils.ResourceName: *resource.NewQuantity(int64(shares), resource.DecimalSI),
+			utils.CountName:    *resource.NewQuantity(int64(count), resource.DecimalSI),
+		}},
+	}
+}
+
+// newTestPod creates the running pod whose containers request the resources in order
+func newTestPod(name string, annotations map[string]string, requests ...v1.ResourceList) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name), Annotations: annotations},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+	for i, r := range requests {
+		pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{
+			Name:      fmt.Sprintf("container-%d", i),
+			Resources: v1.ResourceRequirements{Limits: r},
+		})
+	}
+	return pod
+}
+
+// placeTestPod allocates the XPU shares to the pod and adds it to the node
+func placeTestPod(t *testing.T, n *NodeInfo, pod *v1.Pod) *v1.Pod {
+	allocation, err := n.allocateGPUID(pod)
+	if err != nil {
+		t.Fatalf("failed to place pod %s: %v", pod.Name, err)
+	}
+	newPod := utils.GetUpdatedPodAnnotationSpec(pod, allocation, n.getPhysicalXPUs(), n.getAllXPUCores())
+	n.addOrUpdatePod(newPod)
+	return newPod
+}
+
+func xpuShares(shares int64) v1.ResourceList {
+	return v1.ResourceList{utils.ResourceName: *resource.NewQuantity(shares, resource.DecimalSI)}
+}
+
+func TestGrowXPUShares(t *testing.T) {
+	tests := []struct {
+		name      string
+		shares    map[int]uint
+		maxShares uint
+		available map[int]uint
+		physical  map[int]uint
+		granted   uint
+	}{
+		{
+			name:      "grows to the maximum",
+			shares:    map[int]uint{0: 2},
+			maxShares: 8,
+			available: map[int]uint{0: 16},
+			physical:  map[int]uint{0: 16},
+			granted:   8,
+		},
+		{
+			name:      "grows to the available shares",
+			shares:    map[int]uint{0: 2},
+			maxShares: 12,
+			available: map[int]uint{0: 6},
+			physical:  map[int]uint{0: 16},
+			granted:   6,
+		},
+		{
+			name:      "not beyond the physical capacity when overcommitted",
+			shares:    map[int]uint{0: 2},
+			maxShares: 30,
+			available: map[int]uint{0: 32},
+			physical:  map[int]uint{0: 16},
+			granted:   16,
+		},
+		{
+			name:      "keeps the minimum",
+			shares:    map[int]uint{1: 4},
+			maxShares: 12,
+			available: map[int]uint{1: 2},
+			physical:  map[int]uint{1: 16},
+			granted:   4,
+		},
+		{
+			name:      "split over devices doesn't grow",
+			shares:    map[int]uint{0: 4, 1: 4},
+			maxShares: 12,
+			available: map[int]uint{0: 16, 1: 16},
+			physical:  map[int]uint{0: 16, 1: 16},
+			granted:   8,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if granted := growXPUShares(test.shares, test.maxShares, test.available, test.physical); granted != test.granted {
+				t.Errorf("granted %d, want %d", granted, test.granted)
+			}
+		})
+	}
+}
+
+func TestAllocateElasticOvercommitted(t *testing.T) {
+	setTestConfigMaps(t)
+	n := NewNodeInfo(newTestNode(2, 32, map[string]string{utils.EnvNodeOvercommitRatio: "2"}))
+	pod := newTestPod("elastic", map[string]string{utils.EnvResourceMaxShares: "30"}, xpuShares(2))
+
+	allocation, err := n.allocateGPUID(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices := utils.GetXPUSharesByDevFromAllocation(allocation)
+	if !reflect.DeepEqual(devices, map[int]uint{0: 16}) && !reflect.DeepEqual(devices, map[int]uint{1: 16}) {
+		t.Errorf("allocated %v, want 16 XPU shares on one device", devices)
+	}
+}
+
+func TestUpdateNodeCoresByDevice(t *testing.T) {
+	node := newTestNode(2, 32, nil)
+	node.Status.Capacity[utils.CoreName] = *resource.NewQuantity(200, resource.DecimalSI)
+	node.ResourceVersion = "1"
+	n := NewNodeInfo(node)
+
+	updated := node.DeepCopy()
+	updated.ResourceVersion = "2"
+	updated.Annotations = map[string]string{utils.EnvNodeCoresByDevIndex: "0:60,1:140"}
+	n.updateNode(updated)
+	for id, cores := range map[int]uint{0: 60, 1: 140} {
+		if total := n.devs[id].GetDevTotalXPUCores(); total != cores {
+			t.Errorf("GPU[%d] has %d XPU cores, want %d", id, total, cores)
+		}
+	}
+}
+
+func TestAllocateInitContainers(t *testing.T) {
+	setTestConfigMaps(t)
+	tests := []struct {
+		name    string
+		used    []int64
+		init    int64
+		devices map[int]uint
+		fail    bool
+	}{
+		{name: "on the device of the container", used: nil, init: 14, devices: map[int]uint{0: 14}},
+		{name: "moves to the device where the init container fits", used: []int64{4}, init: 14, devices: map[int]uint{1: 14}},
+		{name: "smaller than the container", used: nil, init: 6, devices: map[int]uint{0: 12}},
+		{name: "no device fits the init container", used: []int64{14, 4}, init: 14, fail: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := NewNodeInfo(newTestNode(2, 32, nil))
+			for i, shares := range test.used {
+				placeTestPod(t, n, newTestPod(fmt.Sprintf("used-%d", i), nil, xpuShares(shares)))
+			}
+			pod := newTestPod("pod", nil, xpuShares(12))
+			pod.Spec.InitContainers = []v1.Container{{Name: "init", Resources: v1.ResourceRequirements{Limits: xpuShares(test.init)}}}
+
+			allocation, err := n.allocateGPUID(pod)
+			if test.fail {
+				if err == nil {
+					t.Errorf("allocated %v, want no allocation", allocation)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if devices := utils.GetXPUSharesByDevFromAllocation(allocation); !reflect.DeepEqual(devices, test.devices) {
+				t.Errorf("allocated %v, want %v", devices, test.devices)
+			}
+		})
+	}
+}
+
+func TestAllocateWholeDevicesInitContainers(t *testing.T) {
+	setTestConfigMaps(t)
+	n := NewNodeInfo(newTestNode(2, 32, nil))
+	counts := func(count int64) v1.ResourceList {
+		return v1.ResourceList{utils.CountName: *resource.NewQuantity(count, resource.DecimalSI)}
+	}
+	pod := newTestPod("pod", nil, counts(1))
+	pod.Spec.InitContainers = []v1.Container{{Name: "init", Resources: v1.ResourceRequirements{Limits: counts(2)}}}
+
+	allocation, err := n.allocateGPUID(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if devices := utils.GetXPUSharesByDevFromAllocation(allocation); !reflect.DeepEqual(devices, map[int]uint{0: 16, 1: 16}) {
+		t.Errorf("allocated %v, want 2 whole devices", devices)
+	}
+}
+
+func TestSplitXPUShares(t *testing.T) {
+	topology := `[["X","SYS","SYS"],["SYS","X","NV2"],["SYS","NV2","X"]]`
+	tests := []struct {
+		name       string
+		topology   string
+		reqShares  uint
+		available  map[int]uint
+		allocation map[int]uint
+		found      bool
+	}{
+		{
+			name:       "one device",
+			reqShares:  12,
+			available:  map[int]uint{0: 8, 1: 16},
+			allocation: map[int]uint{1: 12},
+			found:      true,
+		},
+		{
+			name:       "the devices with the most available shares",
+			reqShares:  12,
+			available:  map[int]uint{0: 8, 1: 8, 2: 4},
+			allocation: map[int]uint{0: 8, 1: 4},
+			found:      true,
+		},
+		{
+			name:       "the devices with the most link bandwidth",
+			topology:   topology,
+			reqShares:  12,
+			available:  map[int]uint{0: 8, 1: 8, 2: 8},
+			allocation: map[int]uint{1: 8, 2: 4},
+			found:      true,
+		},
+		{
+			name:      "not enough",
+			reqShares: 12,
+			available: map[int]uint{0: 4, 1: 4, 2: 0},
+			found:     false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if len(test.topology) > 0 {
+				annotations[utils.EnvNodeTopology] = test.topology
+			}
+			n := NewNodeInfo(newTestNode(len(test.available), 16*len(test.available), annotations))
+			allocation, found := n.splitXPUShares(test.reqShares, test.available)
+			if found != test.found {
+				t.Fatalf("found %v, want %v", found, test.found)
+			}
+			if found && !reflect.DeepEqual(allocation, test.allocation) {
+				t.Errorf("allocated %v, want %v", allocation, test.allocation)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+)
+
+var (
+	defaultOvercommitRatio = 1.0
+	overcommitLock         = new(sync.RWMutex)
+)
+
+// SetDefaultOvercommitRatio sets the cluster default overcommit ratio of the XPU shares,
+// it's used by the nodes without the overcommit annotation
+func SetDefaultOvercommitRatio(ratio float64) error {
+	if ratio < 1 {
+		return fmt.Errorf("overcommit ratio %v is less than 1", ratio)
+	}
+	overcommitLock.Lock()
+	defer overcommitLock.Unlock()
+	defaultOvercommitRatio = ratio
+	return nil
+}
+
+// GetDefaultOvercommitRatio gets the cluster default overcommit ratio of the XPU shares
+func GetDefaultOvercommitRatio() float64 {
+	overcommitLock.RLock()
+	defer overcommitLock.RUnlock()
+	return defaultOvercommitRatio
+}
+
+// GetOvercommitRatios gets the overcommit ratio of each device in the node, device index: ratio
+func (n *NodeInfo) GetOvercommitRatios() map[int]float64 {
+	n.rwmu.RLock()
+	defer n.rwmu.RUnlock()
+	return n.getOvercommitRatios()
+}
+
+// GetEffectiveXPUShares gets the capacity of each device with the overcommit ratio, device index: XPU shares
+func (n *NodeInfo) GetEffectiveXPUShares() map[int]uint {
+	n.rwmu.RLock()
+	defer n.rwmu.RUnlock()
+	return n.getAllXPUs()
+}
+
+// device index: overcommit ratio
+func (n *NodeInfo) getOvercommitRatios() map[int]float64 {
+	return utils.GetOvercommitRatioByDev(n.node, GetDefaultOvercommitRatio())
+}
+
+// overcommit is the effective capacity of the physical XPU shares with the ratio
+func overcommit(totalXPUShares uint, ratio float64) uint {
+	if ratio <= 1 {
+		return totalXPUShares
+	}
+	return uint(float64(totalXPUShares) * ratio)
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"log"
+
+	"k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+	policylisters "k8s.io/client-go/listers/policy/v1beta1"
+	clientgocache "k8s.io/client-go/tools/cache"
+)
+
+var (
+	PodDisruptionBudgetLister         policylisters.PodDisruptionBudgetLister
+	PodDisruptionBudgetInformerSynced clientgocache.InformerSynced
+)
+
+// getPodDisruptionBudgets gets the PodDisruptionBudgets matching the pod, it's empty if none matches
+func getPodDisruptionBudgets(pod *v1.Pod) []*policy.PodDisruptionBudget {
+	if PodDisruptionBudgetLister == nil {
+		return nil
+	}
+
+	// an error is returned only if no PodDisruptionBudget matches the pod
+	pdbs, err := PodDisruptionBudgetLister.GetPodPodDisruptionBudgets(pod)
+	if err != nil {
+		log.Printf("debug: no PodDisruptionBudget for pod [%s] in namespace [%s]: %v", pod.Name, pod.Namespace, err)
+		return nil
+	}
+
+	return pdbs
+}
+
+// CountPodDisruptionBudgetViolations counts the pods violating their PodDisruptionBudgets when all of them are evicted
+func CountPodDisruptionBudgetViolations(pods []*v1.Pod) (numPDBViolations int) {
+	disruptionsAllowed := map[types.UID]int32{}
+	for _, pod := range pods {
+		pdbs := getPodDisruptionBudgets(pod)
+		violated := false
+		for _, pdb := range pdbs {
+			if _, ok := disruptionsAllowed[pdb.UID]; !ok {
+				disruptionsAllowed[pdb.UID] = pdb.Status.PodDisruptionsAllowed
+			}
+			if disruptionsAllowed[pdb.UID] <= 0 {
+				violated = true
+			}
+			disruptionsAllowed[pdb.UID]--
+		}
+		if violated {
+			numPDBViolations++
+		}
+	}
+	return numPDBViolations
+}
@@ -0,0 +1,295 @@
+package cache
+
+import (
+	"log"
+	"sort"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PreemptVictims finds the smallest set of pods with lower priority to be preempted, so that one device
+// of the node can provide the XPU shares and cores of the pod. The pods which don't violate PodDisruptionBudgets
+// are preferred. The victims are empty if the pod can be placed without preemption.
+func (n *NodeInfo) PreemptVictims(pod *v1.Pod) (victims []*v1.Pod, numPDBViolations int, found bool) {
+	n.rwmu.RLock()
+	defer n.rwmu.RUnlock()
+
+	reqShares := uint(utils.GetRequestXPUSharesFromPodResource(pod))
+	if reqShares == 0 && !utils.IsWholeDevicePod(pod) {
+		return nil, 0, true
+	}
+
+	if _, err := n.allocateGPUID(pod); err == nil {
+		return nil, 0, true
+	}
+
+	if utils.IsWholeDevicePod(pod) {
+		return n.preemptWholeDevices(pod, utils.GetRequestXPUCountsFromPodResource(pod))
+	}
+	if utils.IsExclusivePod(pod) {
+		return n.preemptWholeDevices(pod, 1)
+	}
+
+	// the victims are only taken from the devices the pod can use, in the same way as the allocation,
+	// and the XPU shares held by the reservations and for the high priority pods stay held after the
+	// preemption, so they are freed besides the request even if the device is hidden from the pod now
+	reqCores := uint(utils.GetRequestXPUCoresFromPodResource(pod))
+	priority := utils.GetPodPriority(pod)
+	physicalXPUShares := n.getPhysicalXPUs()
+	allXPUShares := n.getAllXPUs()
+	reservedXPUShares := n.getReservedXPUs()
+	availableXPUShares := n.getAvailableXPUs()
+	availableXPUCores := n.getAvailableXPUCores()
+	excluded := n.getExcludedDevices(pod)
+	held, _ := n.getHeldXPUs(pod)
+	priorityReserved := map[int]uint{}
+	if !isHighPriorityPod(pod) {
+		priorityReserved = n.getPriorityReservedXPUs()
+	}
+	quotaLimits, limited := n.getNamespaceQuotaLimits(pod)
+	avoided := n.getAvoidedDevices(pod)
+	victimsAvoided := false
+	for devID := 0; devID < len(n.devs); devID++ {
+		availableShares, ok := availableXPUShares[devID]
+		if _, found := excluded[devID]; !ok || found {
+			continue
+		}
+		dev := n.devs[devID]
+		holdShares := held[devID] + priorityReserved[devID]
+		// no preemption makes the device larger than its physical capacity
+		if physicalXPUShares[devID] < reqShares {
+			continue
+		}
+		if allXPUShares[devID] < reqShares+reservedXPUShares[devID]+holdShares || dev.GetDevTotalXPUCores() < reqCores {
+			continue
+		}
+		// the quota per device of the namespace isn't raised by preempting the other namespaces
+		if limited && quotaLimits[devID] < reqShares {
+			continue
+		}
+
+		devVictims, devViolations, devFound := dev.preemptVictims(
+			neededXPU(reqShares+holdShares, availableShares),
+			neededXPU(reqCores, availableXPUCores[devID]),
+			priority)
+		if !devFound {
+			continue
+		}
+		// the device which the pod doesn't avoid wins whatever the victims are
+		if !found || (victimsAvoided && !avoided[devID]) ||
+			(victimsAvoided == avoided[devID] && (devViolations < numPDBViolations ||
+				(devViolations == numPDBViolations && len(devVictims) < len(victims)))) {
+			victims = devVictims
+			numPDBViolations = devViolations
+			victimsAvoided = avoided[devID]
+			found = true
+		}
+	}
+
+	if found {
+		log.Printf("info: preempt %d pods with %d PodDisruptionBudget violations on node [%s] for pod [%s] in namespace [%s]",
+			len(victims),
+			numPDBViolations,
+			n.name,
+			pod.Name,
+			pod.Namespace)
+	} else {
+		log.Printf("info: failed to find preemption victims on node [%s] for pod [%s] in namespace [%s]",
+			n.name,
+			pod.Name,
+			pod.Namespace)
+	}
+	return victims, numPDBViolations, found
+}
+
+// preemptWholeDevices finds the pods with lower priority to be preempted, so that the pod requesting whole
+// devices or the exclusive pod gets enough empty devices. A device is emptied only if all its pods have
+// lower priority and the pod can use the whole device; the devices with the fewest PodDisruptionBudget
+// violations and victims are chosen, and the topology is not considered.
+func (n *NodeInfo) preemptWholeDevices(pod *v1.Pod, reqCounts int) (victims []*v1.Pod, numPDBViolations int, found bool) {
+	type candidate struct {
+		id         int
+		victims    []*v1.Pod
+		violations int
+		avoided    bool
+	}
+
+	// the devices owned by the other pods or not empty for the exclusive pod can be emptied by the preemption,
+	// so only the exclusions which don't depend on the pods on the devices apply
+	excluded := deviceReasons{}
+	n.excludeBySelector(pod, excluded)
+	n.excludeByAffinity(pod, excluded)
+	n.excludeByTaints(pod, excluded)
+	unhealthy := n.getUnhealthyXPUs()
+	unallocatable := n.getUnallocatableXPUs(unhealthy)
+	held, _ := n.getHeldXPUs(pod)
+	priorityReserved := map[int]uint{}
+	if !isHighPriorityPod(pod) {
+		priorityReserved = n.getPriorityReservedXPUs()
+	}
+	quotaLimits, limited := n.getNamespaceQuotaLimits(pod)
+	avoided := n.getAvoidedDevices(pod)
+	physicalXPUShares := n.getPhysicalXPUs()
+	allXPUShares := n.getAllXPUs()
+	reservedXPUShares := n.getReservedXPUs()
+	reqShares := uint(utils.GetRequestXPUSharesFromPodResource(pod))
+	reqCores := uint(utils.GetRequestXPUCoresFromPodResource(pod))
+	priority := utils.GetPodPriority(pod)
+
+	candidates := []candidate{}
+	for devID := 0; devID < len(n.devs); devID++ {
+		dev := n.devs[devID]
+		if _, found := excluded[devID]; found || unhealthy[devID] || unallocatable[devID] || held[devID] > 0 || priorityReserved[devID] > 0 {
+			continue
+		}
+		usable := allXPUShares[devID] - reservedXPUShares[devID]
+		if usable == 0 || usable < reqShares || physicalXPUShares[devID] < reqShares || dev.GetDevTotalXPUCores() < reqCores {
+			continue
+		}
+		if limited {
+			need := reqShares
+			if utils.IsWholeDevicePod(pod) {
+				need = usable
+			}
+			if quotaLimits[devID] < need {
+				continue
+			}
+		}
+
+		c := candidate{id: devID, avoided: avoided[devID]}
+		lower := true
+		for _, p := range dev.getActivePods() {
+			if p.UID == pod.UID {
+				continue
+			}
+			if utils.GetPodPriority(p) >= priority {
+				lower = false
+				break
+			}
+			c.victims = append(c.victims, p)
+		}
+		if !lower {
+			continue
+		}
+		c.violations = CountPodDisruptionBudgetViolations(c.victims)
+		candidates = append(candidates, c)
+	}
+
+	if len(candidates) < reqCounts {
+		return nil, 0, false
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].avoided != candidates[j].avoided {
+			return !candidates[i].avoided
+		}
+		if candidates[i].violations != candidates[j].violations {
+			return candidates[i].violations < candidates[j].violations
+		}
+		return len(candidates[i].victims) < len(candidates[j].victims)
+	})
+
+	// the pod spanning several devices is evicted only once
+	chosen := map[types.UID]bool{}
+	for _, c := range candidates[:reqCounts] {
+		for _, p := range c.victims {
+			if !chosen[p.UID] {
+				chosen[p.UID] = true
+				victims = append(victims, p)
+			}
+		}
+	}
+	return victims, CountPodDisruptionBudgetViolations(victims), true
+}
+
+// neededXPU is the XPU to be freed for the request
+func neededXPU(request uint, available uint) uint {
+	if available >= request {
+		return 0
+	}
+	return request - available
+}
+
+// preemptVictims finds the pods with priority lower than the given one to free the needed XPU shares
+// and cores on the device, the pods not violating PodDisruptionBudgets are tried first
+func (d *DeviceInfo) preemptVictims(neededShares uint, neededCores uint, priority int32) (victims []*v1.Pod, numPDBViolations int, found bool) {
+	d.rwmu.RLock()
+	candidates := []*v1.Pod{}
+	shares := map[types.UID]uint{}
+	cores := map[types.UID]uint{}
+	for _, pod := range d.podMap {
+		if !utils.AssignedNonTerminatedPod(pod) {
+			continue
+		}
+		if utils.GetPodPriority(pod) >= priority {
+			continue
+		}
+		shares[pod.UID] = utils.GetXPUSharesByDevFromPodAnnotation(pod)[d.idx]
+		cores[pod.UID] = utils.GetXPUCoresByDevFromPodAnnotation(pod)[d.idx]
+		if shares[pod.UID] == 0 && cores[pod.UID] == 0 {
+			continue
+		}
+		candidates = append(candidates, pod)
+	}
+	d.rwmu.RUnlock()
+
+	// the fewest victims are found by taking the pods with the most shares first
+	sort.Slice(candidates, func(i, j int) bool {
+		si, sj := shares[candidates[i].UID], shares[candidates[j].UID]
+		if si == sj {
+			return utils.GetPodPriority(candidates[i]) < utils.GetPodPriority(candidates[j])
+		}
+		return si > sj
+	})
+
+	for _, allowViolation := range []bool{false, true} {
+		victims, numPDBViolations, found = selectVictims(candidates, shares, cores, neededShares, neededCores, allowViolation)
+		if found {
+			return victims, numPDBViolations, found
+		}
+	}
+
+	return nil, 0, false
+}
+
+func selectVictims(candidates []*v1.Pod, shares map[types.UID]uint, cores map[types.UID]uint,
+	neededShares uint, neededCores uint, allowViolation bool) (victims []*v1.Pod, numPDBViolations int, found bool) {
+	disruptionsAllowed := map[types.UID]int32{}
+	freedShares := uint(0)
+	freedCores := uint(0)
+	for _, pod := range candidates {
+		if freedShares >= neededShares && freedCores >= neededCores {
+			break
+		}
+
+		pdbs := getPodDisruptionBudgets(pod)
+		violated := false
+		for _, pdb := range pdbs {
+			if _, ok := disruptionsAllowed[pdb.UID]; !ok {
+				disruptionsAllowed[pdb.UID] = pdb.Status.PodDisruptionsAllowed
+			}
+			if disruptionsAllowed[pdb.UID] <= 0 {
+				violated = true
+			}
+		}
+		if violated && !allowViolation {
+			continue
+		}
+
+		for _, pdb := range pdbs {
+			disruptionsAllowed[pdb.UID]--
+		}
+		if violated {
+			numPDBViolations++
+		}
+		victims = append(victims, pod)
+		freedShares += shares[pod.UID]
+		freedCores += cores[pod.UID]
+	}
+
+	if freedShares < neededShares || freedCores < neededCores {
+		return nil, 0, false
+	}
+	return victims, numPDBViolations, true
+}
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	policylisters "k8s.io/client-go/listers/policy/v1beta1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPreemptVictimsOnHeldDevices(t *testing.T) {
+	setTestConfigMaps(t)
+	n := NewNodeInfo(newTestNode(2, 32, nil))
+	for _, name := range []string{"low-1", "low-2", "low-3", "low-4"} {
+		placeTestPod(t, n, newTestPod(name, nil, xpuShares(8)))
+	}
+
+	// the devices are full before a fraction of each is held for the high priority pods
+	SetHighPriorityThreshold(1000)
+	if err := SetHighPriorityFraction(0.25); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		priorityAware = false
+		highPriorityThreshold = 0
+		highPriorityFraction = 0
+	}()
+
+	pod := newTestPod("preemptor", nil, xpuShares(4))
+	pod.Spec.NodeName = ""
+	priority := int32(500)
+	pod.Spec.Priority = &priority
+	victims, _, found := n.PreemptVictims(pod)
+	if !found || len(victims) != 1 {
+		t.Errorf("victims %d found %v, want 1 victim", len(victims), found)
+	}
+}
+
+func TestPreemptVictimsWithoutPods(t *testing.T) {
+	setTestConfigMaps(t)
+	n := NewNodeInfo(newTestNode(1, 16, nil))
+	for name, shares := range map[string]int64{"pod-a": 8, "pod-b": 4, "pod-c": 4} {
+		placeTestPod(t, n, newTestPod(name, nil, xpuShares(shares)))
+	}
+
+	priority := int32(100)
+	tests := []struct {
+		name      string
+		reqShares int64
+		evicted   []types.UID
+		victims   int
+		found     bool
+	}{
+		{name: "enough after the evicted pod", reqShares: 4, evicted: []types.UID{"pod-c"}, victims: 0, found: true},
+		{name: "one more victim", reqShares: 8, evicted: []types.UID{"pod-c"}, victims: 1, found: true},
+		{name: "more than the physical capacity", reqShares: 20, evicted: nil, victims: 0, found: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := newTestPod("preemptor", nil, xpuShares(test.reqShares))
+			pod.Spec.NodeName = ""
+			pod.Spec.Priority = &priority
+			victims, _, found := n.WithoutPods(test.evicted).PreemptVictims(pod)
+			if found != test.found || len(victims) != test.victims {
+				t.Errorf("victims %d found %v, want %d found %v", len(victims), found, test.victims, test.found)
+			}
+			for _, victim := range victims {
+				for _, uid := range test.evicted {
+					if victim.UID == uid {
+						t.Errorf("pod %s is already evicted", uid)
+					}
+				}
+			}
+		})
+	}
+	if !n.devs[0].hasPod("pod-c") {
+		t.Errorf("the node in the cache lost the evicted pod")
+	}
+}
+
+func TestSelectVictims(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(&policy.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "protected", Namespace: "default", UID: "protected"},
+		Spec:       policy.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "protected"}}},
+		Status:     policy.PodDisruptionBudgetStatus{PodDisruptionsAllowed: 0},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	PodDisruptionBudgetLister = policylisters.NewPodDisruptionBudgetLister(indexer)
+	defer func() { PodDisruptionBudgetLister = nil }()
+
+	// the candidates are sorted by the most shares first
+	candidates := []*v1.Pod{
+		newTestPod("pod-8", nil),
+		newTestPod("protected-6", nil),
+		newTestPod("pod-4", nil),
+		newTestPod("pod-2", nil),
+	}
+	candidates[1].Labels = map[string]string{"app": "protected"}
+	shares := map[types.UID]uint{"pod-8": 8, "protected-6": 6, "pod-4": 4, "pod-2": 2}
+	cores := map[types.UID]uint{"pod-8": 0, "protected-6": 0, "pod-4": 50, "pod-2": 0}
+
+	tests := []struct {
+		name           string
+		neededShares   uint
+		neededCores    uint
+		allowViolation bool
+		victims        []string
+		violations     int
+		found          bool
+	}{
+		{name: "nothing needed", victims: nil, found: true},
+		{name: "one victim", neededShares: 8, victims: []string{"pod-8"}, found: true},
+		{name: "skip the protected pod", neededShares: 12, victims: []string{"pod-8", "pod-4"}, found: true},
+		{name: "cores", neededShares: 2, neededCores: 50, victims: []string{"pod-8", "pod-4"}, found: true},
+		{name: "not enough without violations", neededShares: 16, found: false},
+		{name: "violate the PodDisruptionBudget", neededShares: 16, allowViolation: true,
+			victims: []string{"pod-8", "protected-6", "pod-4"}, violations: 1, found: true},
+		{name: "not enough", neededShares: 24, allowViolation: true, found: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			victims, violations, found := selectVictims(candidates, shares, cores, test.neededShares, test.neededCores, test.allowViolation)
+			names := []string{}
+			for _, victim := range victims {
+				names = append(names, victim.Name)
+			}
+			if found != test.found || violations != test.violations || len(names) != len(test.victims) ||
+				(len(names) > 0 && !reflect.DeepEqual(names, test.victims)) {
+				t.Errorf("victims %v violations %d found %v, want %v %d %v", names, violations, found, test.victims, test.violations, test.found)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+	"k8s.io/api/core/v1"
+)
+
+var (
+	// the devices are chosen by the priority of the pod only if the threshold is set
+	priorityAware         bool
+	highPriorityThreshold int32
+	highPriorityStrategy  Strategy = spreadStrategy{}
+	lowPriorityStrategy   Strategy = binpackStrategy{}
+	// the fraction of each device available only to the high priority pods
+	highPriorityFraction float64
+	priorityLock         = new(sync.RWMutex)
+)
+
+// SetHighPriorityThreshold sets the priority from which the pod is high priority, and makes the
+// pods without the strategy annotation choose the devices by their priority
+func SetHighPriorityThreshold(threshold int32) {
+	priorityLock.Lock()
+	defer priorityLock.Unlock()
+	priorityAware = true
+	highPriorityThreshold = threshold
+}
+
+// GetHighPriorityThreshold gets the priority from which the pod is high priority, it's not found
+// if the devices are not chosen by the priority
+func GetHighPriorityThreshold() (threshold int32, found bool) {
+	priorityLock.RLock()
+	defer priorityLock.RUnlock()
+	return highPriorityThreshold, priorityAware
+}
+
+// SetPriorityStrategies sets the strategies of the high priority pods and the others
+func SetPriorityStrategies(high string, low string) error {
+	strategyLock.RLock()
+	highStrategy, highFound := strategies[high]
+	lowStrategy, lowFound := strategies[low]
+	strategyLock.RUnlock()
+	if !highFound {
+		return fmt.Errorf("unknown strategy [%s]", high)
+	}
+	if !lowFound {
+		return fmt.Errorf("unknown strategy [%s]", low)
+	}
+
+	priorityLock.Lock()
+	defer priorityLock.Unlock()
+	highPriorityStrategy = highStrategy
+	lowPriorityStrategy = lowStrategy
+	return nil
+}
+
+// GetPriorityStrategies gets the strategies of the high priority pods and the others
+func GetPriorityStrategies() (high Strategy, low Strategy) {
+	priorityLock.RLock()
+	defer priorityLock.RUnlock()
+	return highPriorityStrategy, lowPriorityStrategy
+}
+
+// SetHighPriorityFraction sets the fraction of each device available only to the high priority pods
+func SetHighPriorityFraction(fraction float64) error {
+	if fraction < 0 || fraction >= 1 {
+		return fmt.Errorf("fraction %v is not in [0, 1)", fraction)
+	}
+	priorityLock.Lock()
+	defer priorityLock.Unlock()
+	highPriorityFraction = fraction
+	return nil
+}
+
+// GetHighPriorityFraction gets the fraction of each device available only to the high priority pods
+func GetHighPriorityFraction() float64 {
+	priorityLock.RLock()
+	defer priorityLock.RUnlock()
+	return highPriorityFraction
+}
+
+// isHighPriorityPod determines if the pod is high priority, it's false if the threshold is not set
+func isHighPriorityPod(pod *v1.Pod) bool {
+	threshold, found := GetHighPriorityThreshold()
+	return found && utils.GetPodPriority(pod) >= threshold
+}
+
+// getPriorityStrategy gets the strategy by the priority of the pod, it's not found if the threshold is not set
+func getPriorityStrategy(pod *v1.Pod) (s Strategy, found bool) {
+	if _, found := GetHighPriorityThreshold(); !found {
+		return nil, false
+	}
+	high, low := GetPriorityStrategies()
+	if isHighPriorityPod(pod) {
+		return high, true
+	}
+	return low, true
+}
+
+// GetPriorityReservedXPUShares gets the XPU shares of each device held for the high priority pods,
+// device index: XPU shares
+func (n *NodeInfo) GetPriorityReservedXPUShares() map[int]uint {
+	n.rwmu.RLock()
+	defer n.rwmu.RUnlock()
+	return n.getPriorityReservedXPUs()
+}
+
+// device index: the fraction of the device available only to the high priority pods, except the
+// XPU shares the high priority pods use there
+func (n *NodeInfo) getPriorityReservedXPUs() (reservedXPUShares map[int]uint) {
+	reservedXPUShares = map[int]uint{}
+	fraction := GetHighPriorityFraction()
+	if _, found := GetHighPriorityThreshold(); !found || fraction == 0 {
+		return reservedXPUShares
+	}
+
+	for id, shares := range n.getAllXPUs() {
+		reserved := uint(float64(shares) * fraction)
+		for _, p := range n.devs[id].getActivePods() {
+			if !isHighPriorityPod(p) {
+				continue
+			}
+			used := utils.GetXPUSharesByDevFromPodAnnotation(p)[id]
+			if used >= reserved {
+				reserved = 0
+				break
+			}
+			reserved -= used
+		}
+		if reserved > 0 {
+			reservedXPUShares[id] = reserved
+		}
+	}
+	return reservedXPUShares
+}
+
+// hideFromLowPriority hides the XPU shares held for the high priority pods from the pod which is not,
+// and excludes the device if all its available XPU shares are held
+func (n *NodeInfo) hideFromLowPriority(pod *v1.Pod, availableXPUShares map[int]uint, excluded deviceReasons) {
+	if isHighPriorityPod(pod) {
+		return
+	}
+	threshold, _ := GetHighPriorityThreshold()
+	for id, reserved := range n.getPriorityReservedXPUs() {
+		availableShares, found := availableXPUShares[id]
+		if !found {
+			continue
+		}
+		if availableShares <= reserved {
+			excluded[id] = fmt.Sprintf("held for the pods with priority %d or higher", threshold)
+			delete(availableXPUShares, id)
+			continue
+		}
+		availableXPUShares[id] = availableShares - reserved
+	}
+}
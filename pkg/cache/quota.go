@@ -0,0 +1,280 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NamespaceQuotaConfigMap is the configmap in kube-system defining the quota of each namespace,
+// the key is the namespace and the value is the quota in JSON, e.g.
+// team-a: '{"maxShares":64,"maxSharesPerDevice":16,"maxDevices":4}'
+const NamespaceQuotaConfigMap = "xpu-namespace-quotas"
+
+// NamespaceQuota limits the XPU shares of the pods in one namespace, 0 is unlimited
+type NamespaceQuota struct {
+	// the XPU shares of all the pods in the namespace
+	MaxShares uint `json:"maxShares,omitempty"`
+	// the XPU shares of the pods in the namespace on one device
+	MaxSharesPerDevice uint `json:"maxSharesPerDevice,omitempty"`
+	// the number of devices which the pods in the namespace use
+	MaxDevices uint `json:"maxDevices,omitempty"`
+}
+
+// NamespaceUsage is the XPU shares used by the pods in one namespace
+type NamespaceUsage struct {
+	Shares uint
+	// node name: device index: XPU shares
+	Devices map[string]map[int]uint
+}
+
+// GetNamespaceQuotas gets the quota of each namespace, namespace: quota
+func GetNamespaceQuotas() map[string]NamespaceQuota {
+	quotas := map[string]NamespaceQuota{}
+	cm := getConfigMap(NamespaceQuotaConfigMap)
+	if cm == nil {
+		return quotas
+	}
+
+	for namespace, value := range cm.Data {
+		quota := NamespaceQuota{}
+		if err := json.Unmarshal([]byte(value), &quota); err != nil {
+			log.Printf("warn: failed to parse the quota [%s] of namespace [%s] due to %v", value, namespace, err)
+			continue
+		}
+		quotas[namespace] = quota
+	}
+	return quotas
+}
+
+func getNamespaceQuota(namespace string) (quota NamespaceQuota, found bool) {
+	quota, found = GetNamespaceQuotas()[namespace]
+	return quota, found
+}
+
+// GetNamespaceUsage gets the XPU shares used by the pods in the namespace in all the nodes
+func (cache *SchedulerCache) GetNamespaceUsage(namespace string) NamespaceUsage {
+	return cache.getNamespaceUsage(namespace, "")
+}
+
+// CheckNamespaceQuota checks if the pod placed on the node keeps its namespace in the quota
+// of the total XPU shares and the number of devices, the XPU shares per device are limited
+// when the devices are chosen
+func (cache *SchedulerCache) CheckNamespaceQuota(pod *v1.Pod, n *NodeInfo) error {
+	quota, found := getNamespaceQuota(pod.Namespace)
+	if !found || (quota.MaxShares == 0 && quota.MaxDevices == 0) {
+		return nil
+	}
+
+	allocation, err := n.assumeXPUs(pod)
+	if err != nil {
+		return err
+	}
+
+	usage := cache.getNamespaceUsage(pod.Namespace, pod.UID)
+	var reqShares uint
+	for _, shares := range allocation {
+		reqShares += shares
+	}
+	// the elastic pod fits if its minimum fits, it grows in the devices within the quota per device
+	if elastic, minShares, _ := utils.GetElasticXPUSharesFromAnnotation(pod); len(elastic) > 0 && minShares < reqShares {
+		reqShares = minShares
+	}
+	if quota.MaxShares > 0 && usage.Shares+reqShares > quota.MaxShares {
+		return fmt.Errorf("namespace %s exceeds the quota of XPU shares (%d + %d > %d)",
+			pod.Namespace,
+			usage.Shares,
+			reqShares,
+			quota.MaxShares)
+	}
+
+	if quota.MaxDevices > 0 {
+		devices := usage.CountDevices()
+		for id := range allocation {
+			if _, found := usage.Devices[n.name][id]; !found {
+				devices++
+			}
+		}
+		if devices > quota.MaxDevices {
+			return fmt.Errorf("namespace %s exceeds the quota of devices (%d > %d)",
+				pod.Namespace,
+				devices,
+				quota.MaxDevices)
+		}
+	}
+
+	return nil
+}
+
+// Allocate allocates the XPU shares for the pod and binds it to the node
+func (cache *SchedulerCache) Allocate(clientset *kubernetes.Clientset, n *NodeInfo, pod *v1.Pod) error {
+	log.Printf("info: beginning to allocate XPU shares for pod [%s] in namespace [%s]", pod.Name, pod.Namespace)
+	newPod, err := cache.Reserve(n, pod)
+	if err == nil {
+		err = n.Commit(clientset, pod, newPod)
+	}
+	log.Printf("info: ending to allocate XPU shares for pod [%s] in namespace [%s]", pod.Name, pod.Namespace)
+	return err
+}
+
+// Reserve allocates the XPU shares for the pod on the node tentatively if its namespace stays in
+// the quota and its fair share. The pods passing the filter together are checked again one by one,
+// and each is counted by the others once it's reserved.
+func (cache *SchedulerCache) Reserve(n *NodeInfo, pod *v1.Pod) (newPod *v1.Pod, err error) {
+	cache.qLock.Lock()
+	defer cache.qLock.Unlock()
+
+	if err = cache.CheckNamespaceQuota(pod, n); err != nil {
+		return nil, fmt.Errorf("the node %s can't place the pod [%s] in namespace [%s]: %v", n.name, pod.Name, pod.Namespace, err)
+	}
+	if err = cache.CheckFairShare(pod, n); err != nil {
+		return nil, fmt.Errorf("the node %s can't place the pod [%s] in namespace [%s]: %v", n.name, pod.Name, pod.Namespace, err)
+	}
+	return n.Reserve(pod, cache.GetElasticLimit(pod))
+}
+
+// GetElasticLimit gets the most XPU shares which the elastic pod can grow to within the quota of
+// the XPU shares of its namespace and its fair share, it's never less than the minimum of the pod,
+// 0 is unlimited
+func (cache *SchedulerCache) GetElasticLimit(pod *v1.Pod) uint {
+	elastic, minShares, _ := utils.GetElasticXPUSharesFromAnnotation(pod)
+	if len(elastic) == 0 {
+		return 0
+	}
+
+	var limit uint
+	if quota, found := getNamespaceQuota(pod.Namespace); found && quota.MaxShares > 0 {
+		limit = minShares
+		usage := cache.getNamespaceUsage(pod.Namespace, pod.UID)
+		if usage.Shares+minShares < quota.MaxShares {
+			limit = quota.MaxShares - usage.Shares
+		}
+		log.Printf("debug: elastic pod [%s] in namespace [%s] grows to at most %d XPU shares by the quota %d with %d used",
+			pod.Name,
+			pod.Namespace,
+			limit,
+			quota.MaxShares,
+			usage.Shares)
+	}
+	if fair, found := cache.getFairShareLimit(pod); found && (limit == 0 || fair < limit) {
+		limit = minShares
+		if fair > minShares {
+			limit = fair
+		}
+	}
+	return limit
+}
+
+// getNamespaceUsage gets the XPU shares used by the pods in the namespace except the pod
+func (cache *SchedulerCache) getNamespaceUsage(namespace string, except types.UID) NamespaceUsage {
+	cache.nLock.RLock()
+	nodes := []*NodeInfo{}
+	for _, n := range cache.nodes {
+		nodes = append(nodes, n)
+	}
+	cache.nLock.RUnlock()
+
+	usage := NamespaceUsage{Devices: map[string]map[int]uint{}}
+	for _, n := range nodes {
+		n.rwmu.RLock()
+		used := n.getNamespaceXPUs(namespace, except)
+		n.rwmu.RUnlock()
+		if len(used) == 0 {
+			continue
+		}
+		usage.Devices[n.name] = used
+		for _, shares := range used {
+			usage.Shares += shares
+		}
+	}
+	return usage
+}
+
+// CountDevices gets the number of devices used by the namespace
+func (u NamespaceUsage) CountDevices() (devices uint) {
+	for _, used := range u.Devices {
+		devices += uint(len(used))
+	}
+	return devices
+}
+
+// MaxDeviceShares gets the most XPU shares used by the namespace on one device
+func (u NamespaceUsage) MaxDeviceShares() (maxShares uint) {
+	for _, used := range u.Devices {
+		for _, shares := range used {
+			if shares > maxShares {
+				maxShares = shares
+			}
+		}
+	}
+	return maxShares
+}
+
+// getNamespaceXPUs gets the XPU shares used by the pods in the namespace except the pod,
+// device index: XPU shares, the device without such pods is not in the map
+func (n *NodeInfo) getNamespaceXPUs(namespace string, except types.UID) (usedXPUShares map[int]uint) {
+	usedXPUShares = map[int]uint{}
+	for id, dev := range n.devs {
+		for _, p := range dev.getActivePods() {
+			if p.Namespace != namespace || p.UID == except {
+				continue
+			}
+			if shares := utils.GetXPUSharesByDevFromPodAnnotation(p)[id]; shares > 0 {
+				usedXPUShares[id] += shares
+			}
+		}
+	}
+	return usedXPUShares
+}
+
+// limitByNamespaceQuota limits the available XPU shares of each device to the quota per device
+// of the namespace of the pod, and excludes the device if the namespace reaches the quota
+func (n *NodeInfo) limitByNamespaceQuota(pod *v1.Pod, availableXPUShares map[int]uint, excluded deviceReasons) {
+	limits, found := n.getNamespaceQuotaLimits(pod)
+	if !found {
+		return
+	}
+
+	ids := []int{}
+	for id := range availableXPUShares {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if limits[id] == 0 {
+			quota, _ := getNamespaceQuota(pod.Namespace)
+			excluded[id] = fmt.Sprintf("namespace %s reaches the quota of %d XPU shares per device", pod.Namespace, quota.MaxSharesPerDevice)
+			delete(availableXPUShares, id)
+			continue
+		}
+		if availableXPUShares[id] > limits[id] {
+			availableXPUShares[id] = limits[id]
+		}
+	}
+}
+
+// getNamespaceQuotaLimits gets the XPU shares of each device which the namespace of the pod can still use
+// by the quota per device, device index: XPU shares, it's not found if the namespace has no such quota
+func (n *NodeInfo) getNamespaceQuotaLimits(pod *v1.Pod) (limits map[int]uint, found bool) {
+	quota, found := getNamespaceQuota(pod.Namespace)
+	if !found || quota.MaxSharesPerDevice == 0 {
+		return nil, false
+	}
+
+	limits = map[int]uint{}
+	used := n.getNamespaceXPUs(pod.Namespace, pod.UID)
+	for id := range n.devs {
+		if used[id] < quota.MaxSharesPerDevice {
+			limits[id] = quota.MaxSharesPerDevice - used[id]
+		} else {
+			limits[id] = 0
+		}
+	}
+	return limits, true
+}
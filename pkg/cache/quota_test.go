@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReserveInNamespaceQuota(t *testing.T) {
+	setTestConfigMaps(t, &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: NamespaceQuotaConfigMap},
+		Data:       map[string]string{"default": `{"maxShares":6}`},
+	})
+	c := NewSchedulerCache(nil, nil)
+	first := NewNodeInfo(newTestNode(1, 16, nil))
+	second := NewNodeInfo(newTestNode(1, 16, nil))
+	second.name = "node-2"
+	c.nodes[first.name] = first
+	c.nodes[second.name] = second
+
+	// both pods pass the filter before either is bound
+	pods := []*v1.Pod{newTestPod("pod-1", nil, xpuShares(4)), newTestPod("pod-2", nil, xpuShares(4))}
+	for _, pod := range pods {
+		pod.Spec.NodeName = ""
+		if err := c.CheckNamespaceQuota(pod, first); err != nil {
+			t.Fatalf("pod %s doesn't pass the filter: %v", pod.Name, err)
+		}
+	}
+
+	if _, err := c.Reserve(first, pods[0]); err != nil {
+		t.Fatalf("failed to reserve pod %s: %v", pods[0].Name, err)
+	}
+	if _, err := c.Reserve(second, pods[1]); err == nil {
+		t.Errorf("pod %s is reserved beyond the quota of its namespace", pods[1].Name)
+	}
+	if usage := c.GetNamespaceUsage("default"); usage.Shares != 4 {
+		t.Errorf("namespace uses %d XPU shares, want 4", usage.Shares)
+	}
+}
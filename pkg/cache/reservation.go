@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ReservationConfigMap is the configmap in kube-system defining the reservations of XPU shares,
+// the key is the name of the reservation and the value is the reservation in JSON, e.g.
+// eval-run: '{"nodeName":"node1","devices":[0,1],"shares":0,"start":"2021-06-01T00:00:00Z",
+// "end":"2021-06-02T00:00:00Z","namespaces":["eval"],"podSelector":{"matchLabels":{"job":"eval"}}}'
+const ReservationConfigMap = "xpu-reservations"
+
+// Reservation holds the XPU shares of the devices free in a time window for the pods it selects
+type Reservation struct {
+	Name string `json:"-"`
+	// the node of the devices, or the labels of the nodes if it's empty
+	NodeName     string            `json:"nodeName,omitempty"`
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// the indexes of the devices, all the devices of the node if it's empty
+	Devices []int `json:"devices,omitempty"`
+	// the XPU shares held in each device, 0 is all the XPU shares of the device
+	Shares uint `json:"shares,omitempty"`
+	// the time window [start, end)
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// the pods which can use the reservation, the namespaces are all if it's empty
+	Namespaces  []string              `json:"namespaces,omitempty"`
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty"`
+}
+
+// IsActive checks if the time is in the window of the reservation
+func (r *Reservation) IsActive(now time.Time) bool {
+	return !now.Before(r.Start) && now.Before(r.End)
+}
+
+// matchNode checks if the reservation holds the devices of the node
+func (r *Reservation) matchNode(node *v1.Node) bool {
+	if len(r.NodeName) > 0 {
+		return r.NodeName == node.Name
+	}
+	if len(r.NodeSelector) == 0 {
+		return false
+	}
+	return labels.SelectorFromSet(labels.Set(r.NodeSelector)).Matches(labels.Set(node.Labels))
+}
+
+// matchDevice checks if the reservation holds the device
+func (r *Reservation) matchDevice(id int) bool {
+	if len(r.Devices) == 0 {
+		return true
+	}
+	for _, devID := range r.Devices {
+		if devID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPod checks if the pod can use the reservation
+func (r *Reservation) matchPod(pod *v1.Pod) bool {
+	if len(r.Namespaces) > 0 {
+		found := false
+		for _, namespace := range r.Namespaces {
+			if namespace == pod.Namespace {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.PodSelector == nil {
+		return true
+	}
+	selector, err := metav1.LabelSelectorAsSelector(r.PodSelector)
+	if err != nil {
+		log.Printf("warn: invalid pod selector of reservation [%s] due to %v", r.Name, err)
+		return false
+	}
+	return selector.Matches(labels.Set(pod.Labels))
+}
+
+// GetReservations gets the reservations sorted by the name
+func GetReservations() []*Reservation {
+	reservations := []*Reservation{}
+	cm := getConfigMap(ReservationConfigMap)
+	if cm == nil {
+		return reservations
+	}
+
+	for name, value := range cm.Data {
+		r := &Reservation{}
+		if err := json.Unmarshal([]byte(value), r); err != nil {
+			log.Printf("warn: failed to parse the reservation [%s] due to %v", name, err)
+			continue
+		}
+		r.Name = name
+		reservations = append(reservations, r)
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		return reservations[i].Name < reservations[j].Name
+	})
+	return reservations
+}
+
+// getHeldXPUs gets the XPU shares held by the active reservations of the node which the pod can't use,
+// device index: XPU shares. The XPU shares used by the pods of a reservation are taken from it.
+func (n *NodeInfo) getHeldXPUs(pod *v1.Pod) (heldXPUShares map[int]uint, holders map[int]*Reservation) {
+	heldXPUShares = map[int]uint{}
+	holders = map[int]*Reservation{}
+	now := time.Now()
+	allXPUShares := n.getAllXPUs()
+	for _, r := range GetReservations() {
+		if !r.IsActive(now) || !r.matchNode(n.node) || r.matchPod(pod) {
+			continue
+		}
+		for id, dev := range n.devs {
+			if !r.matchDevice(id) {
+				continue
+			}
+			held := r.Shares
+			if held == 0 || held > allXPUShares[id] {
+				held = allXPUShares[id]
+			}
+			for _, p := range dev.getActivePods() {
+				if p.UID == pod.UID || !r.matchPod(p) {
+					continue
+				}
+				used := utils.GetXPUSharesByDevFromPodAnnotation(p)[id]
+				if used >= held {
+					held = 0
+					break
+				}
+				held -= used
+			}
+			if held > 0 {
+				heldXPUShares[id] += held
+				holders[id] = r
+			}
+		}
+	}
+	return heldXPUShares, holders
+}
+
+// hideReservations hides the XPU shares held by the reservations from the pod which can't use them,
+// and excludes the device if all its available XPU shares are held
+func (n *NodeInfo) hideReservations(pod *v1.Pod, availableXPUShares map[int]uint, excluded deviceReasons) {
+	held, holders := n.getHeldXPUs(pod)
+	for id, shares := range held {
+		availableShares, found := availableXPUShares[id]
+		if !found {
+			continue
+		}
+		if availableShares <= shares {
+			excluded[id] = fmt.Sprintf("held by reservation %s until %s", holders[id].Name, holders[id].End.Format(time.RFC3339))
+			delete(availableXPUShares, id)
+			continue
+		}
+		availableXPUShares[id] = availableShares - shares
+	}
+}
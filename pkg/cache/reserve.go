@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"sync"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+)
+
+var (
+	defaultReservedXPUShares uint
+	reserveLock              = new(sync.RWMutex)
+)
+
+// SetDefaultReservedXPUShares sets the cluster default XPU shares of each device reserved for
+// the system daemons, it's used by the nodes without the reserve annotation
+func SetDefaultReservedXPUShares(reserved uint) {
+	reserveLock.Lock()
+	defer reserveLock.Unlock()
+	defaultReservedXPUShares = reserved
+}
+
+// GetDefaultReservedXPUShares gets the cluster default XPU shares of each device reserved for the system daemons
+func GetDefaultReservedXPUShares() uint {
+	reserveLock.RLock()
+	defer reserveLock.RUnlock()
+	return defaultReservedXPUShares
+}
+
+// GetReservedXPUShares gets the reserved XPU shares of each device in the node, device index: XPU shares
+func (n *NodeInfo) GetReservedXPUShares() map[int]uint {
+	n.rwmu.RLock()
+	defer n.rwmu.RUnlock()
+	return n.getReservedXPUs()
+}
+
+// device index: reserved XPU shares, it's not more than the capacity of the device
+func (n *NodeInfo) getReservedXPUs() (reservedXPUShares map[int]uint) {
+	reservedXPUShares = utils.GetReservedXPUSharesByDev(n.node, GetDefaultReservedXPUShares())
+	allXPUShares := n.getAllXPUs()
+	for id, reserved := range reservedXPUShares {
+		if reserved > allXPUShares[id] {
+			reservedXPUShares[id] = allXPUShares[id]
+		}
+	}
+	return reservedXPUShares
+}
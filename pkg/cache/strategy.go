@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+	"k8s.io/api/core/v1"
+)
+
+const (
+	BinpackStrategyName  = "binpack"
+	SpreadStrategyName   = "spread"
+	FirstFitStrategyName = "first-fit"
+)
+
+// Strategy decides which device the XPU shares of a pod are placed on
+type Strategy interface {
+	// Name is the value used in the pod annotation and the startup config
+	Name() string
+	// Score rates placing reqShares on the device devID which has availableShares,
+	// the device with the highest score is chosen, and the lower index wins the tie
+	Score(devID int, availableShares uint, reqShares uint) int
+}
+
+var (
+	strategies      = map[string]Strategy{}
+	defaultStrategy Strategy
+	strategyLock    = new(sync.RWMutex)
+)
+
+func init() {
+	RegisterStrategy(binpackStrategy{})
+	RegisterStrategy(spreadStrategy{})
+	RegisterStrategy(firstFitStrategy{})
+	RegisterStrategy(fragmentationStrategy{})
+	defaultStrategy = binpackStrategy{}
+}
+
+// RegisterStrategy makes the strategy selectable by its name
+func RegisterStrategy(s Strategy) {
+	strategyLock.Lock()
+	defer strategyLock.Unlock()
+	strategies[s.Name()] = s
+}
+
+// SetDefaultStrategy sets the cluster default strategy, it's used when the pod doesn't choose one
+func SetDefaultStrategy(name string) error {
+	strategyLock.Lock()
+	defer strategyLock.Unlock()
+	s, found := strategies[name]
+	if !found {
+		return fmt.Errorf("unknown strategy [%s]", name)
+	}
+	defaultStrategy = s
+	return nil
+}
+
+// GetDefaultStrategy gets the cluster default strategy
+func GetDefaultStrategy() Strategy {
+	strategyLock.RLock()
+	defer strategyLock.RUnlock()
+	return defaultStrategy
+}
+
+// getStrategy gets the strategy chosen by the pod annotation, or by the priority of the pod
+// if the high priority threshold is set, or the cluster default
+func getStrategy(pod *v1.Pod) Strategy {
+	name := utils.GetStrategyFromAnnotation(pod)
+	if len(name) == 0 {
+		if s, found := getPriorityStrategy(pod); found {
+			return s
+		}
+		return GetDefaultStrategy()
+	}
+
+	strategyLock.RLock()
+	s, found := strategies[name]
+	strategyLock.RUnlock()
+	if !found {
+		log.Printf("warn: unknown strategy [%s] for pod [%s] in namespace [%s], use the default strategy",
+			name,
+			pod.Name,
+			pod.Namespace)
+		return GetDefaultStrategy()
+	}
+	return s
+}
+
+// binpackStrategy prefers the device with the least available shares
+type binpackStrategy struct{}
+
+func (binpackStrategy) Name() string {
+	return BinpackStrategyName
+}
+
+func (binpackStrategy) Score(devID int, availableShares uint, reqShares uint) int {
+	return -int(availableShares)
+}
+
+// spreadStrategy prefers the device with the most available shares
+type spreadStrategy struct{}
+
+func (spreadStrategy) Name() string {
+	return SpreadStrategyName
+}
+
+func (spreadStrategy) Score(devID int, availableShares uint, reqShares uint) int {
+	return int(availableShares)
+}
+
+// firstFitStrategy prefers the device with the lowest index
+type firstFitStrategy struct{}
+
+func (firstFitStrategy) Name() string {
+	return FirstFitStrategyName
+}
+
+func (firstFitStrategy) Score(devID int, availableShares uint, reqShares uint) int {
+	return 0
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+)
+
+var (
+	// the thresholds of the device readings, 0 is not checked
+	maxTemperature float64
+	maxPower       float64
+	// the telemetry older than it is ignored
+	telemetryStaleness = 5 * time.Minute
+	telemetryLock      = new(sync.RWMutex)
+)
+
+// SetTelemetryThresholds sets the temperature in Celsius and the power draw in watts above which
+// the device is hot, 0 is not checked
+func SetTelemetryThresholds(temperature float64, power float64) error {
+	if temperature < 0 || power < 0 {
+		return fmt.Errorf("thresholds %v and %v can't be negative", temperature, power)
+	}
+	telemetryLock.Lock()
+	defer telemetryLock.Unlock()
+	maxTemperature = temperature
+	maxPower = power
+	return nil
+}
+
+// GetTelemetryThresholds gets the temperature in Celsius and the power draw in watts above which
+// the device is hot, 0 is not checked
+func GetTelemetryThresholds() (temperature float64, power float64) {
+	telemetryLock.RLock()
+	defer telemetryLock.RUnlock()
+	return maxTemperature, maxPower
+}
+
+// SetTelemetryStaleness sets the age from which the telemetry of the node is ignored
+func SetTelemetryStaleness(staleness time.Duration) error {
+	if staleness <= 0 {
+		return fmt.Errorf("staleness %v is not positive", staleness)
+	}
+	telemetryLock.Lock()
+	defer telemetryLock.Unlock()
+	telemetryStaleness = staleness
+	return nil
+}
+
+// GetTelemetryStaleness gets the age from which the telemetry of the node is ignored
+func GetTelemetryStaleness() time.Duration {
+	telemetryLock.RLock()
+	defer telemetryLock.RUnlock()
+	return telemetryStaleness
+}
+
+// GetHotDevices gets the devices whose readings are above the thresholds, device index: reason
+func (n *NodeInfo) GetHotDevices() map[int]string {
+	n.rwmu.RLock()
+	defer n.rwmu.RUnlock()
+	return n.getHotDevices()
+}
+
+// device index: the reading above the threshold, it's empty if the telemetry is missing or stale
+func (n *NodeInfo) getHotDevices() (hot map[int]string) {
+	hot = map[int]string{}
+	temperature, power := GetTelemetryThresholds()
+	if temperature == 0 && power == 0 {
+		return hot
+	}
+	telemetry, found := utils.GetDeviceTelemetry(n.node)
+	if !found || time.Since(telemetry.Timestamp) > GetTelemetryStaleness() {
+		return hot
+	}
+
+	for id, readings := range telemetry.Devices {
+		if id < 0 || id >= len(n.devs) {
+			continue
+		}
+		if temperature > 0 && readings.Temperature > temperature {
+			hot[id] = fmt.Sprintf("temperature %v > %v", readings.Temperature, temperature)
+		} else if power > 0 && readings.Power > power {
+			hot[id] = fmt.Sprintf("power %v > %v", readings.Power, power)
+		}
+	}
+	return hot
+}
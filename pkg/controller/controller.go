@@ -111,6 +111,11 @@ func NewController(clientset *kubernetes.Clientset, kubeInformerFactory kubeinfo
 	cache.ConfigMapLister = cmInformer.Lister()
 	cache.ConfigMapInformerSynced = cmInformer.Informer().HasSynced
 
+	// Create podDisruptionBudget informer
+	pdbInformer := kubeInformerFactory.Policy().V1beta1().PodDisruptionBudgets()
+	cache.PodDisruptionBudgetLister = pdbInformer.Lister()
+	cache.PodDisruptionBudgetInformerSynced = pdbInformer.Informer().HasSynced
+
 	// Start informer goroutines.
 	go kubeInformerFactory.Start(stopCh)
 
@@ -137,6 +142,12 @@ func NewController(clientset *kubernetes.Clientset, kubeInformerFactory kubeinfo
 		log.Println("info: init the configmap cache successfully")
 	}
 
+	if ok := clientgocache.WaitForCacheSync(stopCh, cache.PodDisruptionBudgetInformerSynced); !ok {
+		return nil, fmt.Errorf("failed to wait for podDisruptionBudget caches to sync")
+	} else {
+		log.Println("info: init the podDisruptionBudget cache successfully")
+	}
+
 	log.Println("info: end to wait for cache")
 
 	return c, nil
@@ -252,6 +263,9 @@ func (c *Controller) addPodToCache(obj interface{}) {
 	// 	return
 	// }
 
+	// keep the pending demand of each namespace for the fair share
+	c.schedulerCache.UpdatePendingPod(pod)
+
 	podKey, err := KeyFunc(pod)
 	if err != nil {
 		log.Printf("warn: failed to get the jobkey: %v", err)
@@ -276,6 +290,7 @@ func (c *Controller) updatePodInCache(oldObj, newObj interface{}) {
 		return
 	}
 	needUpdate := false
+	c.schedulerCache.UpdatePendingPod(newPod)
 
 	podUID := oldPod.UID
 
@@ -333,6 +348,7 @@ func (c *Controller) deletePodFromCache(obj interface{}) {
 	}
 
 	log.Printf("debug: delete pod [%s] in namespace [%s]", pod.Name, pod.Namespace)
+	c.schedulerCache.RemovePendingPod(pod)
 	podKey, err := KeyFunc(pod)
 	if err != nil {
 		log.Printf("warn: failed to get the jobkey: %v", err)
@@ -20,6 +20,8 @@ const (
 	apiPrefix         = "/xpu-schd-ext"
 	bindPrefix        = apiPrefix + "/bind"
 	predicatesPrefix  = apiPrefix + "/filter"
+	prioritizePrefix  = apiPrefix + "/prioritize"
+	preemptPrefix     = apiPrefix + "/preempt"
 	inspectPrefix     = apiPrefix + "/inspect/:nodename"
 	inspectListPrefix = apiPrefix + "/inspect"
 )
@@ -98,6 +100,76 @@ func PredicateRoute(predicate *scheduler.Predicate) httprouter.Handle {
 	}
 }
 
+func PrioritizeRoute(prioritize *scheduler.Prioritize) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		checkBody(w, r)
+
+		var buf bytes.Buffer
+		body := io.TeeReader(r.Body, &buf)
+
+		var extenderArgs schedulerapi.ExtenderArgs
+		var hostPriorityList *schedulerapi.HostPriorityList
+
+		if err := json.NewDecoder(body).Decode(&extenderArgs); err != nil {
+			log.Printf("warn: failed to parse request due to error %v", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			errMsg := fmt.Sprintf("{'error':'%s'}", err.Error())
+			w.Write([]byte(errMsg))
+			return
+		}
+		hostPriorityList = prioritize.Handler(extenderArgs)
+
+		if resultBody, err := json.Marshal(hostPriorityList); err != nil {
+			log.Printf("warn: failed due to %v", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			errMsg := fmt.Sprintf("{'error':'%s'}", err.Error())
+			w.Write([]byte(errMsg))
+		} else {
+			log.Print("info: ", prioritize.Name, " hostPriorityList = ", string(resultBody))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(resultBody)
+		}
+	}
+}
+
+func PreemptRoute(preempt *scheduler.Preempt) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		checkBody(w, r)
+
+		var buf bytes.Buffer
+		body := io.TeeReader(r.Body, &buf)
+
+		var extenderPreemptionArgs schedulerapi.ExtenderPreemptionArgs
+		var extenderPreemptionResult *schedulerapi.ExtenderPreemptionResult
+
+		if err := json.NewDecoder(body).Decode(&extenderPreemptionArgs); err != nil {
+			log.Printf("warn: failed to parse request due to error %v", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			errMsg := fmt.Sprintf("{'error':'%s'}", err.Error())
+			w.Write([]byte(errMsg))
+			return
+		}
+		extenderPreemptionResult = preempt.Handler(extenderPreemptionArgs)
+
+		if resultBody, err := json.Marshal(extenderPreemptionResult); err != nil {
+			log.Printf("warn: failed due to %v", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			errMsg := fmt.Sprintf("{'error':'%s'}", err.Error())
+			w.Write([]byte(errMsg))
+		} else {
+			log.Print("info: ", preempt.Name, " extenderPreemptionResult = ", string(resultBody))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(resultBody)
+		}
+	}
+}
+
 func BindRoute(bind *scheduler.Bind) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		checkBody(w, r)
@@ -168,6 +240,14 @@ func AddPredicate(router *httprouter.Router, predicate *scheduler.Predicate) {
 	router.POST(predicatesPrefix, DebugLogging(PredicateRoute(predicate), predicatesPrefix))
 }
 
+func AddPrioritize(router *httprouter.Router, prioritize *scheduler.Prioritize) {
+	router.POST(prioritizePrefix, DebugLogging(PrioritizeRoute(prioritize), prioritizePrefix))
+}
+
+func AddPreempt(router *httprouter.Router, preempt *scheduler.Preempt) {
+	router.POST(preemptPrefix, DebugLogging(PreemptRoute(preempt), preemptPrefix))
+}
+
 func AddBind(router *httprouter.Router, bind *scheduler.Bind) {
 	if handle, _, _ := router.Lookup("POST", bindPrefix); handle != nil {
 		log.Print("warning: AddBind was called more then once, do nothing")
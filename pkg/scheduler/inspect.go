@@ -1,6 +1,9 @@
 package scheduler
 
 import (
+	"sort"
+	"time"
+
 	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/cache"
 	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
 )
@@ -24,22 +27,114 @@ func (in Inspect) Handler(name string) *Result {
 	}
 
 	return &Result{
-		Nodes: nodes,
-		Error: errMsg,
+		Nodes:         nodes,
+		Fragmentation: in.cache.GetFragmentation(),
+		Quotas:        buildQuotas(in.cache),
+		Reservations:  buildReservations(),
+		Tenants:       buildTenants(in.cache),
+		Priority:      buildPriority(),
+		Error:         errMsg,
+	}
+}
+
+func buildQuotas(c *cache.SchedulerCache) []*Quota {
+	quotas := []*Quota{}
+	for namespace, quota := range cache.GetNamespaceQuotas() {
+		usage := c.GetNamespaceUsage(namespace)
+		quotas = append(quotas, &Quota{
+			Namespace:             namespace,
+			MaxShares:             quota.MaxShares,
+			UsedShares:            usage.Shares,
+			MaxSharesPerDevice:    quota.MaxSharesPerDevice,
+			UsedSharesInOneDevice: usage.MaxDeviceShares(),
+			MaxDevices:            quota.MaxDevices,
+			UsedDevices:           usage.CountDevices(),
+		})
+	}
+	sort.Slice(quotas, func(i, j int) bool {
+		return quotas[i].Namespace < quotas[j].Namespace
+	})
+	return quotas
+}
+
+func buildTenants(c *cache.SchedulerCache) []*Tenant {
+	tenants := []*Tenant{}
+	for _, share := range c.GetTenantShares() {
+		tenants = append(tenants, &Tenant{
+			Namespace:     share.Namespace,
+			Weight:        share.Weight,
+			UsedShares:    share.UsedShares,
+			FairShares:    share.FairShares,
+			PendingPods:   share.PendingPods,
+			PendingShares: share.PendingShares,
+		})
+	}
+	return tenants
+}
+
+func buildPriority() *Priority {
+	threshold, found := cache.GetHighPriorityThreshold()
+	if !found {
+		return nil
+	}
+	high, low := cache.GetPriorityStrategies()
+	return &Priority{
+		HighPriorityThreshold: threshold,
+		HighPriorityStrategy:  high.Name(),
+		LowPriorityStrategy:   low.Name(),
+		ReservedFraction:      cache.GetHighPriorityFraction(),
+	}
+}
+
+func buildReservations() []*Reservation {
+	reservations := []*Reservation{}
+	now := time.Now()
+	for _, r := range cache.GetReservations() {
+		reservations = append(reservations, &Reservation{
+			Name:        r.Name,
+			Active:      r.IsActive(now),
+			Reservation: r,
+		})
 	}
+	return reservations
 }
 
 func buildNode(info *cache.NodeInfo) *Node {
 
 	devInfos := info.GetDevs()
+	attributes := utils.GetDeviceAttributes(info.GetNode())
+	taints := utils.GetDeviceTaints(info.GetNode())
+	effectiveGPU := info.GetEffectiveXPUShares()
+	ratios := info.GetOvercommitRatios()
+	reservedGPU := info.GetReservedXPUShares()
+	priorityGPU := info.GetPriorityReservedXPUShares()
+	hot := info.GetHotDevices()
 	devs := []*Device{}
-	var usedGPU uint
+	var usedGPU, burstGPU, usedCores, totalEffectiveGPU, totalReservedGPU uint
 
 	for i, devInfo := range devInfos {
 		dev := &Device{
-			ID:       i,
-			TotalGPU: devInfo.GetDevTotalXPUShares(),
-			UsedGPU:  devInfo.GetDevUsedXPUShares(),
+			ID:              i,
+			TotalGPU:        devInfo.GetDevTotalXPUShares(),
+			EffectiveGPU:    effectiveGPU[i],
+			OvercommitRatio: ratios[i],
+			ReservedGPU:     reservedGPU[i],
+			PriorityGPU:     priorityGPU[i],
+			UsedGPU:         devInfo.GetDevUsedXPUShares(),
+			BurstGPU:        devInfo.GetDevBurstXPUShares(),
+			TotalCores:      devInfo.GetDevTotalXPUCores(),
+			UsedCores:       devInfo.GetDevUsedXPUCores(),
+		}
+		if len(attributes[i]) > 0 {
+			dev.Attributes = attributes[i]
+		}
+		if len(taints[i]) > 0 {
+			dev.Taints = taints[i]
+		}
+		dev.Hot = hot[i]
+		if owner := devInfo.GetOwner(); owner != nil {
+			dev.Exclusive = true
+			dev.Owner = owner.Namespace + "/" + owner.Name
 		}
 
 		podInfos := devInfo.GetPods()
@@ -49,7 +144,14 @@ func buildNode(info *cache.NodeInfo) *Node {
 				pod := &Pod{
 					Namespace: podInfo.Namespace,
 					Name:      podInfo.Name,
-					UsedGPU:   utils.GetRequestXPUSharesFromPodResource(podInfo),
+					UsedGPU:   int(utils.GetXPUSharesByDevFromPodAnnotation(podInfo)[i]),
+					BurstGPU:  int(utils.GetXPUBurstByDevFromPodAnnotation(podInfo)[i]),
+					UsedCores: int(utils.GetXPUCoresByDevFromPodAnnotation(podInfo)[i]),
+				}
+				for _, container := range utils.GetContainerAllocationFromAnnotation(podInfo) {
+					if _, found := container.Devices[i]; found {
+						pod.Containers = append(pod.Containers, container.Name)
+					}
 				}
 				pods = append(pods, pod)
 			}
@@ -57,13 +159,23 @@ func buildNode(info *cache.NodeInfo) *Node {
 		dev.Pods = pods
 		devs = append(devs, dev)
 		usedGPU += devInfo.GetDevUsedXPUShares()
+		burstGPU += dev.BurstGPU
+		totalEffectiveGPU += effectiveGPU[i]
+		totalReservedGPU += reservedGPU[i]
+		usedCores += devInfo.GetDevUsedXPUCores()
 	}
 
 	return &Node{
-		Name:     info.GetName(),
-		TotalGPU: uint(info.GetNodeTotalGPUMemory()),
-		UsedGPU:  usedGPU,
-		Devices:  devs,
+		Name:          info.GetName(),
+		TotalGPU:      uint(info.GetNodeTotalGPUMemory()),
+		EffectiveGPU:  totalEffectiveGPU,
+		ReservedGPU:   totalReservedGPU,
+		UsedGPU:       usedGPU,
+		BurstGPU:      burstGPU,
+		TotalCores:    uint(info.GetNodeTotalXPUCores()),
+		UsedCores:     usedCores,
+		Fragmentation: info.GetFragmentation(),
+		Devices:       devs,
 	}
 
 }
@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/cache"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+)
+
+// Preempt is responsible for choosing the victims on the nodes for the pod
+type Preempt struct {
+	Name  string
+	Func  func(pod *v1.Pod, nodeName string, victims []types.UID, c *cache.SchedulerCache) (*schedulerapi.MetaVictims, error)
+	cache *cache.SchedulerCache
+}
+
+// Handler handles the Preempt request, the node failed to be preempted is removed from the result
+func (p Preempt) Handler(args schedulerapi.ExtenderPreemptionArgs) *schedulerapi.ExtenderPreemptionResult {
+	pod := args.Pod
+	nodeNameToVictims := map[string][]types.UID{}
+	for nodeName, victims := range args.NodeNameToMetaVictims {
+		uids := []types.UID{}
+		for _, metaPod := range victims.Pods {
+			uids = append(uids, types.UID(metaPod.UID))
+		}
+		nodeNameToVictims[nodeName] = uids
+	}
+	for nodeName, victims := range args.NodeNameToVictims {
+		uids := []types.UID{}
+		for _, victim := range victims.Pods {
+			uids = append(uids, victim.UID)
+		}
+		nodeNameToVictims[nodeName] = uids
+	}
+
+	nodeNameToMetaVictims := map[string]*schedulerapi.MetaVictims{}
+	for nodeName, victims := range nodeNameToVictims {
+		metaVictims, err := p.Func(pod, nodeName, victims, p.cache)
+		if err == nil {
+			nodeNameToMetaVictims[nodeName] = metaVictims
+		}
+	}
+
+	return &schedulerapi.ExtenderPreemptionResult{
+		NodeNameToMetaVictims: nodeNameToMetaVictims,
+	}
+}
@@ -0,0 +1,37 @@
+package scheduler
+
+import (
+	"log"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/cache"
+	"k8s.io/api/core/v1"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+)
+
+// Prioritize is responsible for scoring the nodes for the pod
+type Prioritize struct {
+	Name  string
+	Func  func(pod *v1.Pod, nodeName string, c *cache.SchedulerCache) (int, error)
+	cache *cache.SchedulerCache
+}
+
+// Handler handles the Prioritize request, the node failed to be scored gets 0
+func (p Prioritize) Handler(args schedulerapi.ExtenderArgs) *schedulerapi.HostPriorityList {
+	pod := args.Pod
+	nodeNames := *args.NodeNames
+	priorityList := make(schedulerapi.HostPriorityList, 0, len(nodeNames))
+
+	for _, nodeName := range nodeNames {
+		score, err := p.Func(pod, nodeName, p.cache)
+		if err != nil {
+			log.Printf("warn: failed to score node %s for pod %s in namespace %s due to %v", nodeName, pod.Name, pod.Namespace, err)
+			score = 0
+		}
+		priorityList = append(priorityList, schedulerapi.HostPriority{
+			Host:  nodeName,
+			Score: score,
+		})
+	}
+
+	return &priorityList
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 
 	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/cache"
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -32,7 +33,11 @@ func NewXPUBind(clientset *kubernetes.Clientset, c *cache.SchedulerCache) *Bind
 				return err
 			}
 
-			err = nodeInfo.Allocate(clientset, pod)
+			if name, _ := utils.GetPodGroupFromAnnotation(pod); len(name) > 0 {
+				err = c.BindGroupMember(clientset, nodeInfo, pod)
+			} else {
+				err = c.Allocate(clientset, nodeInfo, pod)
+			}
 			if err != nil {
 				log.Printf("warn: failed to handle pod %s in namespace %s due to error %v", name, namespace, err)
 				return err
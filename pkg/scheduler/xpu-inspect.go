@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/cache"
+	"k8s.io/api/core/v1"
 )
 
 func NewXPUInspect(c *cache.SchedulerCache) *Inspect {
@@ -12,28 +13,90 @@ func NewXPUInspect(c *cache.SchedulerCache) *Inspect {
 }
 
 type Result struct {
-	Nodes []*Node `json:"nodes"`
-	Error string  `json:"error,omitempty"`
+	Nodes         []*Node        `json:"nodes"`
+	Fragmentation float64        `json:"fragmentation"`
+	Quotas        []*Quota       `json:"quotas,omitempty"`
+	Reservations  []*Reservation `json:"reservations,omitempty"`
+	Tenants       []*Tenant      `json:"tenants,omitempty"`
+	Priority      *Priority      `json:"priority,omitempty"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// Quota is the usage of the XPU shares in one namespace against its quota, 0 is unlimited
+type Quota struct {
+	Namespace             string `json:"namespace"`
+	MaxShares             uint   `json:"maxShares"`
+	UsedShares            uint   `json:"usedShares"`
+	MaxSharesPerDevice    uint   `json:"maxSharesPerDevice"`
+	UsedSharesInOneDevice uint   `json:"usedSharesInOneDevice"`
+	MaxDevices            uint   `json:"maxDevices"`
+	UsedDevices           uint   `json:"usedDevices"`
+}
+
+// Tenant is the XPU shares used and pending in one namespace against its fair share
+type Tenant struct {
+	Namespace     string  `json:"namespace"`
+	Weight        float64 `json:"weight"`
+	UsedShares    uint    `json:"usedShares"`
+	FairShares    uint    `json:"fairShares"`
+	PendingPods   int     `json:"pendingPods"`
+	PendingShares uint    `json:"pendingShares"`
+}
+
+// Priority is how the devices are chosen by the priority of the pods
+type Priority struct {
+	HighPriorityThreshold int32   `json:"highPriorityThreshold"`
+	HighPriorityStrategy  string  `json:"highPriorityStrategy"`
+	LowPriorityStrategy   string  `json:"lowPriorityStrategy"`
+	ReservedFraction      float64 `json:"reservedFraction"`
+}
+
+// Reservation is the reservation of XPU shares and if it's in its time window now
+type Reservation struct {
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+	*cache.Reservation
 }
 
 type Node struct {
-	Name     string    `json:"name"`
-	TotalGPU uint      `json:"totalGPU"`
-	UsedGPU  uint      `json:"usedGPU"`
-	Devices  []*Device `json:"devs"`
+	Name          string    `json:"name"`
+	TotalGPU      uint      `json:"totalGPU"`
+	EffectiveGPU  uint      `json:"effectiveGPU"`
+	ReservedGPU   uint      `json:"reservedGPU"`
+	UsedGPU       uint      `json:"usedGPU"`
+	BurstGPU      uint      `json:"burstGPU"`
+	TotalCores    uint      `json:"totalCores"`
+	UsedCores     uint      `json:"usedCores"`
+	Fragmentation float64   `json:"fragmentation"`
+	Devices       []*Device `json:"devs"`
 }
 
 type Device struct {
-	ID       int    `json:"id"`
-	TotalGPU uint   `json:"totalGPU"`
-	UsedGPU  uint   `json:"usedGPU"`
-	Pods     []*Pod `json:"pods"`
+	ID              int               `json:"id"`
+	TotalGPU        uint              `json:"totalGPU"`
+	EffectiveGPU    uint              `json:"effectiveGPU"`
+	OvercommitRatio float64           `json:"overcommitRatio"`
+	ReservedGPU     uint              `json:"reservedGPU"`
+	PriorityGPU     uint              `json:"priorityGPU,omitempty"`
+	UsedGPU         uint              `json:"usedGPU"`
+	BurstGPU        uint              `json:"burstGPU"`
+	TotalCores      uint              `json:"totalCores"`
+	UsedCores       uint              `json:"usedCores"`
+	Exclusive       bool              `json:"exclusive,omitempty"`
+	Owner           string            `json:"owner,omitempty"`
+	Attributes      map[string]string `json:"attributes,omitempty"`
+	Taints          []v1.Taint        `json:"taints,omitempty"`
+	Hot             string            `json:"hot,omitempty"`
+	Pods            []*Pod            `json:"pods"`
 }
 
 type Pod struct {
-	Name      string `json:"name"`
-	Namespace string `json:"namespace"`
-	UsedGPU   int    `json:"usedGPU"`
+	Name       string   `json:"name"`
+	Namespace  string   `json:"namespace"`
+	UsedGPU    int      `json:"usedGPU"`
+	BurstGPU   int      `json:"burstGPU"`
+	UsedCores  int      `json:"usedCores"`
+	Containers []string `json:"containers,omitempty"`
 }
 
 type Inspect struct {
@@ -24,9 +24,22 @@ func NewXPUPredicate(clientset *kubernetes.Clientset, c *cache.SchedulerCache) *
 				return false, fmt.Errorf("the node %s is not for XPU shares, need skip", nodeName)
 			}
 
-			allocatable := nodeInfo.Assume(pod)
+			allocatable, err := nodeInfo.Assume(pod)
 			if !allocatable {
-				return false, fmt.Errorf("insufficient XPU shares in one device")
+				return false, err
+			}
+
+			err = c.CheckNamespaceQuota(pod, nodeInfo)
+			if err == nil {
+				err = c.CheckFairShare(pod, nodeInfo)
+			}
+			if err != nil {
+				log.Printf("info: the pod %s in the namespace %s can't be scheduled on %s due to %v",
+					pod.Name,
+					pod.Namespace,
+					nodeName,
+					err)
+				return false, err
 			} else {
 				log.Printf("info: the pod %s in the namespace %s can be scheduled on %s",
 					pod.Name,
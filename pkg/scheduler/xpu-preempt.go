@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/cache"
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+)
+
+func NewXPUPreempt(clientset *kubernetes.Clientset, c *cache.SchedulerCache) *Preempt {
+	return &Preempt{
+		Name: "xpusharespreempt",
+		Func: func(pod *v1.Pod, nodeName string, victims []types.UID, c *cache.SchedulerCache) (*schedulerapi.MetaVictims, error) {
+			log.Printf("info: check if the pod name %s can preempt pods on node %s", pod.Name, nodeName)
+			nodeInfo, err := c.GetNodeInfo(nodeName)
+			if err != nil {
+				return nil, err
+			}
+
+			if !utils.IsXPUSharesNode(nodeInfo.GetNode()) {
+				return nil, fmt.Errorf("the node %s is not for XPU shares, need skip", nodeName)
+			}
+
+			// the victims chosen by the scheduler are kept, they may be needed for the other resources,
+			// and the pods using XPU shares are added if the XPU shares they free are not enough
+			xpuVictims, _, found := nodeInfo.WithoutPods(victims).PreemptVictims(pod)
+			if !found {
+				return nil, fmt.Errorf("insufficient XPU shares in one device after preemption")
+			}
+
+			metaVictims := &schedulerapi.MetaVictims{
+				Pods: []*schedulerapi.MetaPod{},
+			}
+			for _, uid := range victims {
+				metaVictims.Pods = append(metaVictims.Pods, &schedulerapi.MetaPod{UID: string(uid)})
+			}
+			for _, victim := range xpuVictims {
+				metaVictims.Pods = append(metaVictims.Pods, &schedulerapi.MetaPod{UID: string(victim.UID)})
+			}
+			// the PodDisruptionBudget violations are counted over all the victims
+			metaVictims.NumPDBViolations = cache.CountPodDisruptionBudgetViolations(
+				append(c.GetPodsOnNode(nodeName, victims), xpuVictims...))
+
+			log.Printf("info: the pod %s in the namespace %s can preempt %d pods on %s",
+				pod.Name,
+				pod.Namespace,
+				len(metaVictims.Pods),
+				nodeName)
+			return metaVictims, nil
+		},
+		cache: c,
+	}
+}
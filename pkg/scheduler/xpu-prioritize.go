@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"log"
+
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/cache"
+	"github.com/YoYoContainerService/xpu-scheduler-extender/pkg/utils"
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+)
+
+// PrioritizeWeights are the weights of the parts of the node score
+type PrioritizeWeights struct {
+	// FreeShares prefers the node where the chosen device will be fuller after the placement
+	FreeShares int
+	// Fragmentation prefers the node where the free shares will stay usable for the recent requests after the placement
+	Fragmentation int
+	// Telemetry prefers the node where the chosen devices are not hot by their temperature and power readings
+	Telemetry int
+}
+
+func NewXPUPrioritize(clientset *kubernetes.Clientset, c *cache.SchedulerCache, weights PrioritizeWeights) *Prioritize {
+	return &Prioritize{
+		Name: "xpusharesprioritize",
+		Func: func(pod *v1.Pod, nodeName string, c *cache.SchedulerCache) (int, error) {
+			nodeInfo, err := c.GetNodeInfo(nodeName)
+			if err != nil {
+				return 0, err
+			}
+
+			if !utils.IsXPUSharesNode(nodeInfo.GetNode()) {
+				return 0, nil
+			}
+
+			tightness, compactness, coolness, allocatable := nodeInfo.AssumeScore(pod)
+			if !allocatable {
+				return 0, nil
+			}
+
+			// the score is the same as before without the telemetry thresholds
+			telemetryWeight := weights.Telemetry
+			if temperature, power := cache.GetTelemetryThresholds(); temperature == 0 && power == 0 {
+				telemetryWeight = 0
+			}
+			totalWeight := weights.FreeShares + weights.Fragmentation + telemetryWeight
+			if totalWeight <= 0 {
+				return 0, nil
+			}
+			score := int((float64(weights.FreeShares)*tightness+float64(weights.Fragmentation)*compactness+float64(telemetryWeight)*coolness)*
+				schedulerapi.MaxPriority/float64(totalWeight) + 0.5)
+			log.Printf("debug: the pod %s in the namespace %s scores %d on node %s with tightness %.2f, compactness %.2f and coolness %.2f",
+				pod.Name,
+				pod.Namespace,
+				score,
+				nodeName,
+				tightness,
+				compactness,
+				coolness)
+			return score, nil
+		},
+		cache: c,
+	}
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"encoding/json"
+	"log"
+
+	"k8s.io/api/core/v1"
+)
+
+// ContainerAllocation is the XPU shares allocated to one container of the pod
+type ContainerAllocation struct {
+	// Name of the container
+	Name string `json:"name"`
+	// Devices is device index: XPU shares
+	Devices map[int]uint `json:"devices"`
+	// Cores is device index: XPU cores, it's empty if the container doesn't request XPU cores
+	Cores map[int]uint `json:"cores,omitempty"`
+	// Burst is device index: XPU shares which the container can burst to, it's empty if the container can't burst
+	Burst map[int]uint `json:"burst,omitempty"`
+	// Init is true for the init container, which runs before the other containers
+	Init bool `json:"init,omitempty"`
+}
+
+// GetXPUSharesByDevFromAllocation gets the XPU shares of the pod on each device, device index: XPU shares.
+// Like the effective request of Kubernetes, it's the sum of the containers on the device, or the largest
+// init container on the device if it's more.
+func GetXPUSharesByDevFromAllocation(allocation []ContainerAllocation) (xpuSharesByDev map[int]uint) {
+	return getEffectiveByDev(allocation, func(container ContainerAllocation) map[int]uint {
+		return container.Devices
+	})
+}
+
+// GetXPUCoresByDevFromAllocation gets the XPU cores of the pod on each device in the same way as the XPU shares,
+// device index: XPU cores
+func GetXPUCoresByDevFromAllocation(allocation []ContainerAllocation) (xpuCoresByDev map[int]uint) {
+	return getEffectiveByDev(allocation, func(container ContainerAllocation) map[int]uint {
+		return container.Cores
+	})
+}
+
+// GetXPUBurstByDevFromAllocation gets the XPU shares which the pod can burst to on each device in the same way
+// as the XPU shares, device index: XPU shares, the container which can't burst uses its XPU shares
+func GetXPUBurstByDevFromAllocation(allocation []ContainerAllocation) (xpuBurstByDev map[int]uint) {
+	return getEffectiveByDev(allocation, func(container ContainerAllocation) map[int]uint {
+		if len(container.Burst) > 0 {
+			return container.Burst
+		}
+		return container.Devices
+	})
+}
+
+func getEffectiveByDev(allocation []ContainerAllocation, byDev func(ContainerAllocation) map[int]uint) (effectiveByDev map[int]uint) {
+	effectiveByDev = map[int]uint{}
+	initByDev := map[int]uint{}
+	for _, container := range allocation {
+		for id, value := range byDev(container) {
+			if container.Init {
+				if value > initByDev[id] {
+					initByDev[id] = value
+				}
+			} else {
+				effectiveByDev[id] += value
+			}
+		}
+	}
+	for id, value := range initByDev {
+		if value > effectiveByDev[id] {
+			effectiveByDev[id] = value
+		}
+	}
+	return effectiveByDev
+}
+
+// GetContainerAllocationFromAnnotation gets the allocation of each container from Annotation,
+// it's empty if the pod is allocated before the allocation of containers is recorded
+func GetContainerAllocationFromAnnotation(pod *v1.Pod) []ContainerAllocation {
+	allocation := []ContainerAllocation{}
+	if len(pod.ObjectMeta.Annotations) > 0 {
+		value, found := pod.ObjectMeta.Annotations[EnvResourceByContainer]
+		if found {
+			if err := json.Unmarshal([]byte(value), &allocation); err != nil {
+				log.Printf("warn: failed to parse %s due to %v for pod %s in namespace %s", EnvResourceByContainer, err, pod.Name, pod.Namespace)
+				return []ContainerAllocation{}
+			}
+		}
+	}
+
+	return allocation
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetXPUSharesByDevFromAllocation(t *testing.T) {
+	tests := []struct {
+		name       string
+		allocation []ContainerAllocation
+		byDev      map[int]uint
+	}{
+		{
+			name:  "no containers",
+			byDev: map[int]uint{},
+		},
+		{
+			name: "sum of the containers",
+			allocation: []ContainerAllocation{
+				{Name: "a", Devices: map[int]uint{0: 4}},
+				{Name: "b", Devices: map[int]uint{0: 2, 1: 8}},
+			},
+			byDev: map[int]uint{0: 6, 1: 8},
+		},
+		{
+			name: "the largest init container",
+			allocation: []ContainerAllocation{
+				{Name: "a", Devices: map[int]uint{0: 12}},
+				{Name: "init-a", Devices: map[int]uint{0: 14}, Init: true},
+				{Name: "init-b", Devices: map[int]uint{0: 6}, Init: true},
+			},
+			byDev: map[int]uint{0: 14},
+		},
+		{
+			name: "init container smaller than the containers",
+			allocation: []ContainerAllocation{
+				{Name: "a", Devices: map[int]uint{0: 4}},
+				{Name: "b", Devices: map[int]uint{0: 4}},
+				{Name: "init", Devices: map[int]uint{0: 6}, Init: true},
+			},
+			byDev: map[int]uint{0: 8},
+		},
+		{
+			name: "only init containers",
+			allocation: []ContainerAllocation{
+				{Name: "init-a", Devices: map[int]uint{1: 3}, Init: true},
+				{Name: "init-b", Devices: map[int]uint{1: 5}, Init: true},
+			},
+			byDev: map[int]uint{1: 5},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if byDev := GetXPUSharesByDevFromAllocation(test.allocation); !reflect.DeepEqual(byDev, test.byDev) {
+				t.Errorf("got %v, want %v", byDev, test.byDev)
+			}
+		})
+	}
+}
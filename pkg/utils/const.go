@@ -3,12 +3,47 @@ package utils
 const (
 	ResourceName = "openxpu.com/xpu-shares"
 	CountName    = "openxpu.com/xpu-counts"
+	CoreName     = "openxpu.com/xpu-cores"
 
-	EnvNVGPU              = "NVIDIA_VISIBLE_DEVICES"
-	EnvResourceIndex      = "OPENXPU_XPU_SHARES_INDEX"
-	EnvResourceByPod      = "OPENXPU_XPU_SHARES_POD"
-	EnvResourceByDev      = "OPENXPU_XPU_SHARES_TOTAL"
-	EnvAssignedFlag       = "OPENXPU_XPU_SHARES_ALLOCATED"
-	EnvResourceAssumeTime = "OPENXPU_XPU_SHARES_FILTER_STAMP"
-)
+	EnvNVGPU                   = "NVIDIA_VISIBLE_DEVICES"
+	EnvResourceIndex           = "OPENXPU_XPU_SHARES_INDEX"
+	EnvResourceByPod           = "OPENXPU_XPU_SHARES_POD"
+	EnvResourceByDev           = "OPENXPU_XPU_SHARES_TOTAL"
+	EnvResourceByDevIndex      = "OPENXPU_XPU_SHARES_BY_INDEX"
+	EnvResourceByContainer     = "OPENXPU_XPU_SHARES_CONTAINERS"
+	EnvResourceCoresByPod      = "OPENXPU_XPU_CORES_POD"
+	EnvResourceCoresByDev      = "OPENXPU_XPU_CORES_TOTAL"
+	EnvResourceCoresByDevIndex = "OPENXPU_XPU_CORES_BY_INDEX"
+	EnvResourceBurstByPod      = "OPENXPU_XPU_SHARES_BURST_POD"
+	EnvResourceBurstByDevIndex = "OPENXPU_XPU_SHARES_BURST_BY_INDEX"
+	EnvAssignedFlag            = "OPENXPU_XPU_SHARES_ALLOCATED"
+	EnvResourceAssumeTime      = "OPENXPU_XPU_SHARES_FILTER_STAMP"
+
+	// Node annotations published by the device plugin
+	EnvNodeCapacityByDevIndex = "OPENXPU_XPU_SHARES_CAPACITY_BY_INDEX"
+	EnvNodeCoresByDevIndex    = "OPENXPU_XPU_CORES_CAPACITY_BY_INDEX"
+	EnvNodeTopology           = "OPENXPU_XPU_TOPOLOGY"
+	EnvNodeDeviceAttributes   = "OPENXPU_XPU_DEVICE_ATTRIBUTES"
+
+	// Node annotations published by the node agent collecting the device telemetry
+	EnvNodeDeviceTelemetry = "OPENXPU_XPU_DEVICE_TELEMETRY"
 
+	// Node annotations set by the cluster admin
+	EnvNodeOvercommitRatio = "OPENXPU_XPU_SHARES_OVERCOMMIT_RATIO"
+	EnvNodeReservedShares  = "OPENXPU_XPU_SHARES_RESERVED"
+	EnvNodeDeviceTaints    = "OPENXPU_XPU_DEVICE_TAINTS"
+
+	// Pod annotations set by the user to tune the scheduling
+	EnvResourceMultiDevice    = "OPENXPU_XPU_SHARES_MULTI_DEVICE"
+	EnvResourceStrategy       = "OPENXPU_XPU_SHARES_STRATEGY"
+	EnvResourceSelector       = "OPENXPU_XPU_DEVICE_SELECTOR"
+	EnvResourceAffinity       = "OPENXPU_XPU_DEVICE_AFFINITY"
+	EnvResourceAntiAffinity   = "OPENXPU_XPU_DEVICE_ANTI_AFFINITY"
+	EnvResourceExclusive      = "OPENXPU_XPU_SHARES_EXCLUSIVE"
+	EnvResourceGroup          = "OPENXPU_XPU_POD_GROUP"
+	EnvResourceGroupMinMember = "OPENXPU_XPU_POD_GROUP_MIN_MEMBER"
+	EnvResourceBurstLimits    = "OPENXPU_XPU_SHARES_BURST_LIMITS"
+	EnvResourceTolerations    = "OPENXPU_XPU_DEVICE_TOLERATIONS"
+	EnvResourceMinShares      = "OPENXPU_XPU_SHARES_MIN"
+	EnvResourceMaxShares      = "OPENXPU_XPU_SHARES_MAX"
+)
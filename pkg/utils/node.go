@@ -1,6 +1,15 @@
 package utils
 
-import "k8s.io/api/core/v1"
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+)
 
 // Is the Node for GPU sharing
 func IsXPUSharesNode(node *v1.Node) bool {
@@ -9,8 +18,33 @@ func IsXPUSharesNode(node *v1.Node) bool {
 
 // Get the total XPU capacity of the node
 func GetXPUSharesCapacity(node *v1.Node) int {
-	val, ok := node.Status.Capacity[ResourceName]
+	return getNodeResource(node, ResourceName)
+}
+
+// Get the GPU count of the node
+func GetGPUCountInNode(node *v1.Node) int {
+	return getNodeResource(node, CountName)
+}
+
+// GetXPUCoresCapacity gets the total XPU cores of the node, it's 0 if the cores are not managed
+func GetXPUCoresCapacity(node *v1.Node) int {
+	return getNodeResource(node, CoreName)
+}
+
+// GetXPUSharesAllocatable gets the allocatable XPU shares of the node, or the capacity if the node doesn't report it
+func GetXPUSharesAllocatable(node *v1.Node) int {
+	return getNodeAllocatableResource(node, ResourceName)
+}
+
+// GetGPUCountAllocatable gets the number of the allocatable devices of the node, or the capacity if the
+// node doesn't report it. The device plugin removes the unhealthy devices from it.
+func GetGPUCountAllocatable(node *v1.Node) int {
+	return getNodeAllocatableResource(node, CountName)
+}
 
+// getNodeResource gets the capacity of the resource of the node, the devices are laid out by it
+func getNodeResource(node *v1.Node, name v1.ResourceName) int {
+	val, ok := node.Status.Capacity[name]
 	if !ok {
 		return 0
 	}
@@ -18,13 +52,274 @@ func GetXPUSharesCapacity(node *v1.Node) int {
 	return int(val.Value())
 }
 
-// Get the GPU count of the node
-func GetGPUCountInNode(node *v1.Node) int {
-	val, ok := node.Status.Capacity[CountName]
+// getNodeAllocatableResource gets the allocatable resource of the node, or the capacity if the node doesn't report it
+func getNodeAllocatableResource(node *v1.Node, name v1.ResourceName) int {
+	if val, ok := node.Status.Allocatable[name]; ok {
+		return int(val.Value())
+	}
 
-	if !ok {
-		return int(0)
+	return getNodeResource(node, name)
+}
+
+// GetXPUSharesCapacityByDev gets the XPU shares of each device in the node, device index: XPU shares.
+// The capacity is published in the node annotation like "0:16,1:32", and the device
+// not in the annotation falls back to the even split of the node capacity.
+func GetXPUSharesCapacityByDev(node *v1.Node) (capacityByDev map[int]uint) {
+	return getCapacityByDev(node, EnvNodeCapacityByDevIndex, GetXPUSharesCapacity(node))
+}
+
+// GetXPUCoresCapacityByDev gets the XPU cores of each device in the node, device index: XPU cores,
+// it's published in the same way as the XPU shares
+func GetXPUCoresCapacityByDev(node *v1.Node) (capacityByDev map[int]uint) {
+	return getCapacityByDev(node, EnvNodeCoresByDevIndex, GetXPUCoresCapacity(node))
+}
+
+func getCapacityByDev(node *v1.Node, annotation string, capacity int) (capacityByDev map[int]uint) {
+	capacityByDev = map[int]uint{}
+	count := GetGPUCountInNode(node)
+	if count <= 0 {
+		return capacityByDev
 	}
 
-	return int(val.Value())
+	published := map[int]uint{}
+	if value, found := node.ObjectMeta.Annotations[annotation]; found {
+		var err error
+		published, err = ParseXPUByDev(value)
+		if err != nil {
+			log.Printf("warn: failed to parse %s due to %v for node %s", annotation, err, node.Name)
+		}
+	}
+
+	var total uint
+	for i := 0; i < count; i++ {
+		if s, found := published[i]; found {
+			capacityByDev[i] = s
+		} else {
+			if len(published) > 0 {
+				log.Printf("warn: %s of GPU[%d] is not published for node %s, use the even split", annotation, i, node.Name)
+			}
+			capacityByDev[i] = uint(capacity / count)
+		}
+		total += capacityByDev[i]
+	}
+
+	if len(published) > 0 && total != uint(capacity) {
+		log.Printf("warn: %s of devices %v doesn't match the capacity %d of node %s",
+			annotation,
+			capacityByDev,
+			capacity,
+			node.Name)
+	}
+
+	return capacityByDev
+}
+
+// ParseXPUByDev parses the value like "0:16,1:32" to device index: value,
+// the invalid items are skipped and the last error is returned
+func ParseXPUByDev(value string) (xpuByDev map[int]uint, err error) {
+	xpuByDev = map[int]uint{}
+	for _, item := range strings.Split(value, ",") {
+		pair := strings.SplitN(item, ":", 2)
+		if len(pair) != 2 {
+			err = fmt.Errorf("invalid item [%s]", item)
+			continue
+		}
+		id, e := strconv.Atoi(strings.TrimSpace(pair[0]))
+		if e != nil || id < 0 {
+			err = fmt.Errorf("invalid device index in [%s]", item)
+			continue
+		}
+		s, e := strconv.Atoi(strings.TrimSpace(pair[1]))
+		if e != nil || s < 0 {
+			err = fmt.Errorf("invalid value in [%s]", item)
+			continue
+		}
+		xpuByDev[id] += uint(s)
+	}
+
+	return xpuByDev, err
+}
+
+// GetOvercommitRatioByDev gets the overcommit ratio of each device from the node annotation, which is
+// one ratio for all the devices like "1.5", or the ratio of each device index like "0:2,1:1.5".
+// The device not in the annotation uses defaultRatio, and the ratio less than 1 is ignored.
+func GetOvercommitRatioByDev(node *v1.Node, defaultRatio float64) (ratioByDev map[int]float64) {
+	ratioByDev = map[int]float64{}
+	count := GetGPUCountInNode(node)
+	for i := 0; i < count; i++ {
+		ratioByDev[i] = defaultRatio
+	}
+
+	value, found := node.ObjectMeta.Annotations[EnvNodeOvercommitRatio]
+	if !found {
+		return ratioByDev
+	}
+
+	if !strings.Contains(value, ":") {
+		ratio, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || ratio < 1 {
+			log.Printf("warn: invalid %s [%s] for node %s, use the default ratio %v", EnvNodeOvercommitRatio, value, node.Name, defaultRatio)
+			return ratioByDev
+		}
+		for i := 0; i < count; i++ {
+			ratioByDev[i] = ratio
+		}
+		return ratioByDev
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		pair := strings.SplitN(item, ":", 2)
+		if len(pair) != 2 {
+			log.Printf("warn: invalid item [%s] of %s for node %s", item, EnvNodeOvercommitRatio, node.Name)
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(pair[0]))
+		if err != nil || id < 0 || id >= count {
+			log.Printf("warn: invalid device index in [%s] of %s for node %s", item, EnvNodeOvercommitRatio, node.Name)
+			continue
+		}
+		ratio, err := strconv.ParseFloat(strings.TrimSpace(pair[1]), 64)
+		if err != nil || ratio < 1 {
+			log.Printf("warn: invalid ratio in [%s] of %s for node %s", item, EnvNodeOvercommitRatio, node.Name)
+			continue
+		}
+		ratioByDev[id] = ratio
+	}
+
+	return ratioByDev
+}
+
+// GetReservedXPUSharesByDev gets the XPU shares of each device reserved for the system daemons from the node
+// annotation, which is one value for all the devices like "2", or the value of each device index like "0:2,1:4".
+// The device not in the annotation uses defaultReserved.
+func GetReservedXPUSharesByDev(node *v1.Node, defaultReserved uint) (reservedByDev map[int]uint) {
+	reservedByDev = map[int]uint{}
+	count := GetGPUCountInNode(node)
+	for i := 0; i < count; i++ {
+		reservedByDev[i] = defaultReserved
+	}
+
+	value, found := node.ObjectMeta.Annotations[EnvNodeReservedShares]
+	if !found {
+		return reservedByDev
+	}
+
+	if !strings.Contains(value, ":") {
+		reserved, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || reserved < 0 {
+			log.Printf("warn: invalid %s [%s] for node %s, use the default reserve %d", EnvNodeReservedShares, value, node.Name, defaultReserved)
+			return reservedByDev
+		}
+		for i := 0; i < count; i++ {
+			reservedByDev[i] = uint(reserved)
+		}
+		return reservedByDev
+	}
+
+	published, err := ParseXPUByDev(value)
+	if err != nil {
+		log.Printf("warn: failed to parse %s due to %v for node %s", EnvNodeReservedShares, err, node.Name)
+	}
+	for id, reserved := range published {
+		if id < count {
+			reservedByDev[id] = reserved
+		}
+	}
+
+	return reservedByDev
+}
+
+// GetDeviceTopology gets the link types between the devices of the node, the device plugin publishes
+// them as a JSON matrix in the node annotation, e.g. [["X","NV2"],["NV2","X"]] like `nvidia-smi topo -m`.
+// It's nil if the matrix is not published or doesn't cover all the devices.
+func GetDeviceTopology(node *v1.Node) (topology [][]string) {
+	value, found := node.ObjectMeta.Annotations[EnvNodeTopology]
+	if !found {
+		return nil
+	}
+
+	if err := json.Unmarshal([]byte(value), &topology); err != nil {
+		log.Printf("warn: failed to parse %s due to %v for node %s", EnvNodeTopology, err, node.Name)
+		return nil
+	}
+
+	count := GetGPUCountInNode(node)
+	if len(topology) < count {
+		log.Printf("warn: the topology of node %s has %d devices, less than %d", node.Name, len(topology), count)
+		return nil
+	}
+	for i, links := range topology {
+		if len(links) != len(topology) {
+			log.Printf("warn: the topology of node %s is not a square matrix in row %d", node.Name, i)
+			return nil
+		}
+	}
+
+	return topology
+}
+
+// GetDeviceAttributes gets the attributes of each device in the node, device index: attribute name: value.
+// The device plugin publishes them as JSON in the node annotation, e.g.
+// {"0":{"model":"A100","memory":"40960","computeCapability":"8.0","driverVersion":"470.57.02"}}
+func GetDeviceAttributes(node *v1.Node) (attributes map[int]map[string]string) {
+	attributes = map[int]map[string]string{}
+	value, found := node.ObjectMeta.Annotations[EnvNodeDeviceAttributes]
+	if !found {
+		return attributes
+	}
+
+	if err := json.Unmarshal([]byte(value), &attributes); err != nil {
+		log.Printf("warn: failed to parse %s due to %v for node %s", EnvNodeDeviceAttributes, err, node.Name)
+		return map[int]map[string]string{}
+	}
+
+	return attributes
+}
+
+// GetDeviceTaints gets the taints of each device in the node, device index: taints.
+// The cluster admin sets them as JSON in the node annotation, e.g.
+// {"1":[{"key":"team","value":"x","effect":"NoSchedule"}]}
+func GetDeviceTaints(node *v1.Node) (taints map[int][]v1.Taint) {
+	taints = map[int][]v1.Taint{}
+	value, found := node.ObjectMeta.Annotations[EnvNodeDeviceTaints]
+	if !found {
+		return taints
+	}
+
+	if err := json.Unmarshal([]byte(value), &taints); err != nil {
+		log.Printf("warn: failed to parse %s due to %v for node %s", EnvNodeDeviceTaints, err, node.Name)
+		return map[int][]v1.Taint{}
+	}
+
+	return taints
+}
+
+// DeviceTelemetry is the readings of the devices in the node collected at the timestamp
+type DeviceTelemetry struct {
+	Timestamp time.Time `json:"timestamp"`
+	// device index: readings
+	Devices map[int]DeviceReadings `json:"devices"`
+}
+
+// DeviceReadings is the temperature in Celsius and the power draw in watts of one device, 0 is unknown
+type DeviceReadings struct {
+	Temperature float64 `json:"temperature,omitempty"`
+	Power       float64 `json:"power,omitempty"`
+}
+
+// GetDeviceTelemetry gets the telemetry of the devices in the node, e.g.
+// {"timestamp":"2021-06-01T00:00:00Z","devices":{"0":{"temperature":65,"power":180}}},
+// it's not found if the node doesn't have it
+func GetDeviceTelemetry(node *v1.Node) (telemetry DeviceTelemetry, found bool) {
+	value, found := node.ObjectMeta.Annotations[EnvNodeDeviceTelemetry]
+	if !found {
+		return telemetry, false
+	}
+
+	if err := json.Unmarshal([]byte(value), &telemetry); err != nil {
+		log.Printf("warn: failed to parse %s due to %v for node %s", EnvNodeDeviceTelemetry, err, node.Name)
+		return DeviceTelemetry{}, false
+	}
+
+	return telemetry, true
 }
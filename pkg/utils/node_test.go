@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseXPUByDev(t *testing.T) {
+	tests := []struct {
+		value    string
+		xpuByDev map[int]uint
+		err      bool
+	}{
+		{value: "0:16,1:32", xpuByDev: map[int]uint{0: 16, 1: 32}},
+		{value: " 0 : 16 , 1:8", xpuByDev: map[int]uint{0: 16, 1: 8}},
+		{value: "0:4,0:4", xpuByDev: map[int]uint{0: 8}},
+		{value: "0:16,x:8", xpuByDev: map[int]uint{0: 16}, err: true},
+		{value: "0:16,1:-8", xpuByDev: map[int]uint{0: 16}, err: true},
+		{value: "-1:8,1:8", xpuByDev: map[int]uint{1: 8}, err: true},
+		{value: "16", xpuByDev: map[int]uint{}, err: true},
+		{value: "", xpuByDev: map[int]uint{}, err: true},
+	}
+
+	for _, test := range tests {
+		xpuByDev, err := ParseXPUByDev(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("ParseXPUByDev(%q) error %v, want error %v", test.value, err, test.err)
+		}
+		if !reflect.DeepEqual(xpuByDev, test.xpuByDev) {
+			t.Errorf("ParseXPUByDev(%q) = %v, want %v", test.value, xpuByDev, test.xpuByDev)
+		}
+	}
+}
+
+func TestGetOvercommitRatioByDev(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		ratioByDev map[int]float64
+	}{
+		{name: "default", ratioByDev: map[int]float64{0: 1.2, 1: 1.2}},
+		{name: "all the devices", annotation: "1.5", ratioByDev: map[int]float64{0: 1.5, 1: 1.5}},
+		{name: "less than 1", annotation: "0.5", ratioByDev: map[int]float64{0: 1.2, 1: 1.2}},
+		{name: "invalid", annotation: "x", ratioByDev: map[int]float64{0: 1.2, 1: 1.2}},
+		{name: "by device", annotation: "0:2,1:1.5", ratioByDev: map[int]float64{0: 2, 1: 1.5}},
+		{name: "device not in the annotation", annotation: "1:3", ratioByDev: map[int]float64{0: 1.2, 1: 3}},
+		{name: "invalid items", annotation: "0:0.5,2:2,1:x", ratioByDev: map[int]float64{0: 1.2, 1: 1.2}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node := &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1", Annotations: map[string]string{}},
+				Status: v1.NodeStatus{Capacity: v1.ResourceList{
+					CountName: *resource.NewQuantity(2, resource.DecimalSI),
+				}},
+			}
+			if len(test.annotation) > 0 {
+				node.Annotations[EnvNodeOvercommitRatio] = test.annotation
+			}
+			if ratioByDev := GetOvercommitRatioByDev(node, 1.2); !reflect.DeepEqual(ratioByDev, test.ratioByDev) {
+				t.Errorf("got %v, want %v", ratioByDev, test.ratioByDev)
+			}
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // AssignedNonTerminatedPod selects pods that are assigned and non-terminal (scheduled and running).
@@ -36,27 +41,171 @@ func IsCompletePod(pod *v1.Pod) bool {
 	return false
 }
 
-// IsGPUsharingPod determines if it's the pod for GPU sharing
+// GetPodPriority gets the priority of the pod, it's 0 if the priority is not set
+func GetPodPriority(pod *v1.Pod) int32 {
+	if pod.Spec.Priority != nil {
+		return *pod.Spec.Priority
+	}
+
+	return 0
+}
+
+// GetPodGroupFromAnnotation gets the pod group which must be placed all together and its minimum
+// number of members, the name is empty if the pod doesn't belong to a group of more than one pod
+func GetPodGroupFromAnnotation(pod *v1.Pod) (name string, minMember int) {
+	if len(pod.ObjectMeta.Annotations) == 0 {
+		return "", 0
+	}
+	name, found := pod.ObjectMeta.Annotations[EnvResourceGroup]
+	if !found || len(name) == 0 {
+		return "", 0
+	}
+
+	value := pod.ObjectMeta.Annotations[EnvResourceGroupMinMember]
+	minMember, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("warn: failed to parse %s [%s] due to %v for pod %s in namespace %s", EnvResourceGroupMinMember, value, err, pod.Name, pod.Namespace)
+		return "", 0
+	}
+	if minMember <= 1 {
+		return "", 0
+	}
+
+	return name, minMember
+}
+
+// IsGPUsharingPod determines if it's the pod for GPU sharing, or the pod requesting whole devices
 func IsGPUsharingPod(pod *v1.Pod) bool {
-	return GetRequestXPUSharesFromPodResource(pod) > 0
+	return GetRequestXPUSharesFromPodResource(pod) > 0 || IsWholeDevicePod(pod)
 }
 
-// GetGPUIDFromAnnotation gets GPU ID from Annotation
+// IsWholeDevicePod determines if the pod requests whole devices which can't be shared with other pods
+func IsWholeDevicePod(pod *v1.Pod) bool {
+	return GetRequestXPUCountsFromPodResource(pod) > 0
+}
+
+// IsExclusivePod determines if the pod requesting XPU shares holds its devices exclusively
+func IsExclusivePod(pod *v1.Pod) bool {
+	if len(pod.ObjectMeta.Annotations) > 0 {
+		value, found := pod.ObjectMeta.Annotations[EnvResourceExclusive]
+		if found {
+			exclusive, err := strconv.ParseBool(value)
+			if err != nil {
+				log.Printf("warn: failed to parse %s [%s] due to %v for pod %s in namespace %s", EnvResourceExclusive, value, err, pod.Name, pod.Namespace)
+				return false
+			}
+			return exclusive
+		}
+	}
+
+	return false
+}
+
+// GetGPUIDFromAnnotation gets GPU ID from Annotation, it's the first one if the pod spans several devices
 func GetGPUIDFromAnnotation(pod *v1.Pod) int {
-	id := -1
+	ids := GetGPUIDsFromAnnotation(pod)
+	if len(ids) == 0 {
+		return -1
+	}
+
+	return ids[0]
+}
+
+// GetGPUIDsFromAnnotation gets all the GPU IDs from Annotation, the value is a comma separated list
+func GetGPUIDsFromAnnotation(pod *v1.Pod) []int {
+	ids := []int{}
 	if len(pod.ObjectMeta.Annotations) > 0 {
 		value, found := pod.ObjectMeta.Annotations[EnvResourceIndex]
 		if found {
-			var err error
-			id, err = strconv.Atoi(value)
+			for _, sid := range strings.Split(value, ",") {
+				id, err := strconv.Atoi(strings.TrimSpace(sid))
+				if err != nil || id < 0 {
+					log.Printf("warn: failed to parse GPU ID [%s] due to %v for pod %s in namespace %s", sid, err, pod.Name, pod.Namespace)
+					return []int{}
+				}
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids
+}
+
+// IsMultiDeviceRequest determines if the pod allows its XPU shares to be separated to different devices
+func IsMultiDeviceRequest(pod *v1.Pod) bool {
+	if len(pod.ObjectMeta.Annotations) > 0 {
+		value, found := pod.ObjectMeta.Annotations[EnvResourceMultiDevice]
+		if found {
+			multi, err := strconv.ParseBool(value)
 			if err != nil {
-				log.Printf("warn: failed due to %v for pod %s in namespace %s", err, pod.Name, pod.Namespace)
-				id = -1
+				log.Printf("warn: failed to parse %s [%s] due to %v for pod %s in namespace %s", EnvResourceMultiDevice, value, err, pod.Name, pod.Namespace)
+				return false
 			}
+			return multi
 		}
 	}
 
-	return id
+	return false
+}
+
+// GetStrategyFromAnnotation gets the device selection strategy chosen by the pod
+func GetStrategyFromAnnotation(pod *v1.Pod) string {
+	if len(pod.ObjectMeta.Annotations) > 0 {
+		return strings.TrimSpace(pod.ObjectMeta.Annotations[EnvResourceStrategy])
+	}
+
+	return ""
+}
+
+// GetDeviceSelectorFromAnnotation gets the requirements of the device attributes from Annotation,
+// e.g. [{"key":"model","operator":"NotIn","values":["T4"]}]
+func GetDeviceSelectorFromAnnotation(pod *v1.Pod) (requirements []v1.NodeSelectorRequirement, err error) {
+	if len(pod.ObjectMeta.Annotations) > 0 {
+		value, found := pod.ObjectMeta.Annotations[EnvResourceSelector]
+		if found {
+			if err = json.Unmarshal([]byte(value), &requirements); err != nil {
+				log.Printf("warn: failed to parse %s due to %v for pod %s in namespace %s", EnvResourceSelector, err, pod.Name, pod.Namespace)
+				return nil, err
+			}
+		}
+	}
+
+	return requirements, nil
+}
+
+// GetDeviceTolerationsFromAnnotation gets the tolerations of the device taints from Annotation,
+// e.g. [{"key":"team","operator":"Equal","value":"x","effect":"NoSchedule"}]
+func GetDeviceTolerationsFromAnnotation(pod *v1.Pod) (tolerations []v1.Toleration) {
+	if len(pod.ObjectMeta.Annotations) > 0 {
+		value, found := pod.ObjectMeta.Annotations[EnvResourceTolerations]
+		if found {
+			if err := json.Unmarshal([]byte(value), &tolerations); err != nil {
+				log.Printf("warn: failed to parse %s due to %v for pod %s in namespace %s", EnvResourceTolerations, err, pod.Name, pod.Namespace)
+				return nil
+			}
+		}
+	}
+
+	return tolerations
+}
+
+// GetDeviceAffinityFromAnnotation gets the label selector of the pods from the annotation key,
+// which is EnvResourceAffinity or EnvResourceAntiAffinity, e.g. {"matchLabels":{"app":"trainer"}}.
+// It's nil if the pod doesn't set the annotation.
+func GetDeviceAffinityFromAnnotation(pod *v1.Pod, key string) (selector labels.Selector, err error) {
+	if len(pod.ObjectMeta.Annotations) > 0 {
+		value, found := pod.ObjectMeta.Annotations[key]
+		if found {
+			labelSelector := &metav1.LabelSelector{}
+			if err = json.Unmarshal([]byte(value), labelSelector); err != nil {
+				log.Printf("warn: failed to parse %s due to %v for pod %s in namespace %s", key, err, pod.Name, pod.Namespace)
+				return nil, err
+			}
+			return metav1.LabelSelectorAsSelector(labelSelector)
+		}
+	}
+
+	return nil, nil
 }
 
 // GetGPUIDFromEnv gets GPU ID from Env
@@ -112,6 +261,65 @@ func GetXPUSharesFromPodAnnotation(pod *v1.Pod) (xpuShares uint) {
 	return xpuShares
 }
 
+// GetXPUSharesByDevFromPodAnnotation gets the XPU shares of the pod on each device, device index: XPU shares
+func GetXPUSharesByDevFromPodAnnotation(pod *v1.Pod) (xpuSharesByDev map[int]uint) {
+	if len(pod.ObjectMeta.Annotations) > 0 {
+		value, found := pod.ObjectMeta.Annotations[EnvResourceByDevIndex]
+		if found {
+			var err error
+			xpuSharesByDev, err = ParseXPUByDev(value)
+			if err != nil {
+				log.Printf("warn: failed to parse %s due to %v for pod %s in namespace %s", EnvResourceByDevIndex, err, pod.Name, pod.Namespace)
+			}
+			return xpuSharesByDev
+		}
+	}
+
+	// the pod is allocated in one device
+	xpuSharesByDev = map[int]uint{}
+	id := GetGPUIDFromAnnotation(pod)
+	if id >= 0 {
+		xpuSharesByDev[id] = GetXPUSharesFromPodAnnotation(pod)
+	}
+
+	return xpuSharesByDev
+}
+
+// GetXPUBurstByDevFromPodAnnotation gets the XPU shares which the pod can burst to on each device,
+// device index: XPU shares, it's the allocated XPU shares if the pod can't burst
+func GetXPUBurstByDevFromPodAnnotation(pod *v1.Pod) (xpuBurstByDev map[int]uint) {
+	if len(pod.ObjectMeta.Annotations) > 0 {
+		value, found := pod.ObjectMeta.Annotations[EnvResourceBurstByDevIndex]
+		if found {
+			var err error
+			xpuBurstByDev, err = ParseXPUByDev(value)
+			if err != nil {
+				log.Printf("warn: failed to parse %s due to %v for pod %s in namespace %s", EnvResourceBurstByDevIndex, err, pod.Name, pod.Namespace)
+			}
+			return xpuBurstByDev
+		}
+	}
+
+	return GetXPUSharesByDevFromPodAnnotation(pod)
+}
+
+// GetXPUCoresByDevFromPodAnnotation gets the XPU cores of the pod on each device, device index: XPU cores
+func GetXPUCoresByDevFromPodAnnotation(pod *v1.Pod) (xpuCoresByDev map[int]uint) {
+	xpuCoresByDev = map[int]uint{}
+	if len(pod.ObjectMeta.Annotations) > 0 {
+		value, found := pod.ObjectMeta.Annotations[EnvResourceCoresByDevIndex]
+		if found {
+			var err error
+			xpuCoresByDev, err = ParseXPUByDev(value)
+			if err != nil {
+				log.Printf("warn: failed to parse %s due to %v for pod %s in namespace %s", EnvResourceCoresByDevIndex, err, pod.Name, pod.Namespace)
+			}
+		}
+	}
+
+	return xpuCoresByDev
+}
+
 // GetXPUSharesFromPodEnv gets the GPU Memory of the pod
 func GetXPUSharesFromPodEnv(pod *v1.Pod) (xpuShares uint) {
 	for _, container := range pod.Spec.Containers {
@@ -142,27 +350,134 @@ loop:
 	return xpuShares
 }
 
-// GetRequestXPUSharesFromPodResource gets XPU shares of the Pod
+// GetRequestXPUSharesFromPodResource gets XPU shares of the Pod,
+// like the effective request of Kubernetes, it's the sum of the containers, or the largest init container if it's more
 func GetRequestXPUSharesFromPodResource(pod *v1.Pod) int {
-	var total int
-	containers := pod.Spec.Containers
-	for _, container := range containers {
-		if val, ok := container.Resources.Limits[ResourceName]; ok {
-			total += int(val.Value())
+	return getEffectiveRequest(pod, GetRequestXPUSharesFromContainerResource)
+}
+
+// GetRequestXPUCoresFromPodResource gets XPU cores of the Pod in the same way as the XPU shares
+func GetRequestXPUCoresFromPodResource(pod *v1.Pod) int {
+	return getEffectiveRequest(pod, GetRequestXPUCoresFromContainerResource)
+}
+
+func getEffectiveRequest(pod *v1.Pod, request func(v1.Container) int) int {
+	var total, maxInit int
+	for _, container := range pod.Spec.Containers {
+		total += request(container)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if r := request(container); r > maxInit {
+			maxInit = r
 		}
 	}
+	if maxInit > total {
+		return maxInit
+	}
+	return total
+}
+
+// GetRequestXPUCoresFromContainerResource gets XPU cores of the Container
+func GetRequestXPUCoresFromContainerResource(container v1.Container) int {
+	var total int
+	if val, ok := container.Resources.Limits[CoreName]; ok {
+		total += int(val.Value())
+	}
+	return total
+}
+
+// GetRequestXPUCountsFromPodResource gets the number of whole devices of the Pod in the same way as the XPU shares
+func GetRequestXPUCountsFromPodResource(pod *v1.Pod) int {
+	return getEffectiveRequest(pod, GetRequestXPUCountsFromContainerResource)
+}
+
+// GetRequestXPUCountsFromContainerResource gets the number of whole devices of the Container
+func GetRequestXPUCountsFromContainerResource(container v1.Container) int {
+	var total int
+	if val, ok := container.Resources.Limits[CountName]; ok {
+		total += int(val.Value())
+	}
 	return total
 }
 
-// GetRequestXPUSharesFromContainerResource gets GPU Memory of the Container
+// GetRequestXPUSharesFromContainerResource gets GPU Memory of the Container, it's the guaranteed
+// XPU shares in the requests, or in the limits if the requests don't have them
 func GetRequestXPUSharesFromContainerResource(container v1.Container) int {
 	var total int
-	if val, ok := container.Resources.Limits[ResourceName]; ok {
+	if val, ok := container.Resources.Requests[ResourceName]; ok {
+		total += int(val.Value())
+	} else if val, ok := container.Resources.Limits[ResourceName]; ok {
 		total += int(val.Value())
 	}
 	return total
 }
 
+// GetLimitXPUSharesFromContainerResource gets the XPU shares which the container can burst to, it's the
+// value of the container in the annotation EnvResourceBurstLimits, e.g. {"notebook":16}, or in the limits.
+// It's the guaranteed XPU shares if it's less than them.
+func GetLimitXPUSharesFromContainerResource(pod *v1.Pod, container v1.Container) int {
+	request := GetRequestXPUSharesFromContainerResource(container)
+	limit := 0
+	if val, ok := container.Resources.Limits[ResourceName]; ok {
+		limit = int(val.Value())
+	}
+	if value, found := pod.ObjectMeta.Annotations[EnvResourceBurstLimits]; found {
+		limits := map[string]int{}
+		if err := json.Unmarshal([]byte(value), &limits); err != nil {
+			log.Printf("warn: failed to parse %s due to %v for pod %s in namespace %s", EnvResourceBurstLimits, err, pod.Name, pod.Namespace)
+		} else if l, found := limits[container.Name]; found {
+			limit = l
+		}
+	}
+	if limit < request {
+		return request
+	}
+	return limit
+}
+
+// GetElasticXPUSharesFromAnnotation gets the range of XPU shares of the elastic container, which is the only
+// container of the pod requesting XPU shares. The minimum is the annotation EnvResourceMinShares or its request,
+// and the maximum is the annotation EnvResourceMaxShares. The name is empty if the pod is not elastic.
+func GetElasticXPUSharesFromAnnotation(pod *v1.Pod) (name string, minShares uint, maxShares uint) {
+	value, found := pod.ObjectMeta.Annotations[EnvResourceMaxShares]
+	if !found {
+		return "", 0, 0
+	}
+	max, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		log.Printf("warn: failed to parse %s [%s] due to %v for pod %s in namespace %s", EnvResourceMaxShares, value, err, pod.Name, pod.Namespace)
+		return "", 0, 0
+	}
+
+	for _, container := range pod.Spec.Containers {
+		if GetRequestXPUSharesFromContainerResource(container) == 0 {
+			continue
+		}
+		if len(name) > 0 {
+			log.Printf("warn: pod %s in namespace %s has more than one container requesting XPU shares, %s is ignored", pod.Name, pod.Namespace, EnvResourceMaxShares)
+			return "", 0, 0
+		}
+		name = container.Name
+		minShares = uint(GetRequestXPUSharesFromContainerResource(container))
+	}
+	if len(name) == 0 {
+		return "", 0, 0
+	}
+
+	if value, found := pod.ObjectMeta.Annotations[EnvResourceMinShares]; found {
+		min, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || min == 0 {
+			log.Printf("warn: failed to parse %s [%s] due to %v for pod %s in namespace %s", EnvResourceMinShares, value, err, pod.Name, pod.Namespace)
+			return "", 0, 0
+		}
+		minShares = uint(min)
+	}
+	if uint(max) <= minShares {
+		return "", 0, 0
+	}
+	return name, minShares, uint(max)
+}
+
 // GetUpdatedPodEnvSpec updates pod env with devId
 func GetUpdatedPodEnvSpec(oldPod *v1.Pod, devId int, totalXPUSharesByDev int) (newPod *v1.Pod) {
 	newPod = oldPod.DeepCopy()
@@ -186,17 +501,65 @@ func GetUpdatedPodEnvSpec(oldPod *v1.Pod, devId int, totalXPUSharesByDev int) (n
 	return newPod
 }
 
-// GetUpdatedPodAnnotationSpec updates pod env with devId
-func GetUpdatedPodAnnotationSpec(oldPod *v1.Pod, devId int, totalXPUSharesByDev int) (newPod *v1.Pod) {
+// GetUpdatedPodAnnotationSpec updates pod annotation with the allocation of each container, the total
+// XPU shares and cores of each allocated device are written in the same order as the device indexes
+func GetUpdatedPodAnnotationSpec(oldPod *v1.Pod, allocation []ContainerAllocation, totalXPUSharesByDev map[int]uint, totalXPUCoresByDev map[int]uint) (newPod *v1.Pod) {
 	newPod = oldPod.DeepCopy()
 	if len(newPod.ObjectMeta.Annotations) == 0 {
 		newPod.ObjectMeta.Annotations = map[string]string{}
 	}
 
+	xpuSharesByDev := GetXPUSharesByDevFromAllocation(allocation)
+	xpuCoresByDev := GetXPUCoresByDevFromAllocation(allocation)
+	xpuBurstByDev := GetXPUBurstByDevFromAllocation(allocation)
+	ids := []int{}
+	for id := range xpuSharesByDev {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	sids := []string{}
+	totals := []string{}
+	sharesByDev := []string{}
+	coreTotals := []string{}
+	coresByDev := []string{}
+	burstByDev := []string{}
+	var xpuShares, xpuCores, xpuBurst uint
+	for _, id := range ids {
+		xpuShares += xpuSharesByDev[id]
+		xpuCores += xpuCoresByDev[id]
+		xpuBurst += xpuBurstByDev[id]
+		sids = append(sids, fmt.Sprintf("%d", id))
+		totals = append(totals, fmt.Sprintf("%d", totalXPUSharesByDev[id]))
+		sharesByDev = append(sharesByDev, fmt.Sprintf("%d:%d", id, xpuSharesByDev[id]))
+		coreTotals = append(coreTotals, fmt.Sprintf("%d", totalXPUCoresByDev[id]))
+		coresByDev = append(coresByDev, fmt.Sprintf("%d:%d", id, xpuCoresByDev[id]))
+		burstByDev = append(burstByDev, fmt.Sprintf("%d:%d", id, xpuBurstByDev[id]))
+	}
+
+	containers, err := json.Marshal(allocation)
+	if err != nil {
+		log.Printf("warn: failed to marshal the allocation %v due to %v for pod %s in namespace %s", allocation, err, newPod.Name, newPod.Namespace)
+	} else {
+		newPod.ObjectMeta.Annotations[EnvResourceByContainer] = string(containers)
+	}
+
+	if xpuCores > 0 {
+		newPod.ObjectMeta.Annotations[EnvResourceCoresByPod]      = fmt.Sprintf("%d", xpuCores)
+		newPod.ObjectMeta.Annotations[EnvResourceCoresByDev]      = strings.Join(coreTotals, ",")
+		newPod.ObjectMeta.Annotations[EnvResourceCoresByDevIndex] = strings.Join(coresByDev, ",")
+	}
+
+	if xpuBurst > xpuShares {
+		newPod.ObjectMeta.Annotations[EnvResourceBurstByPod]      = fmt.Sprintf("%d", xpuBurst)
+		newPod.ObjectMeta.Annotations[EnvResourceBurstByDevIndex] = strings.Join(burstByDev, ",")
+	}
+
 	now := time.Now()
-	newPod.ObjectMeta.Annotations[EnvResourceIndex]      = fmt.Sprintf("%d", devId)
-	newPod.ObjectMeta.Annotations[EnvResourceByDev]      = fmt.Sprintf("%d", totalXPUSharesByDev)
-	newPod.ObjectMeta.Annotations[EnvResourceByPod]      = fmt.Sprintf("%d", GetRequestXPUSharesFromPodResource(newPod))
+	newPod.ObjectMeta.Annotations[EnvResourceIndex]      = strings.Join(sids, ",")
+	newPod.ObjectMeta.Annotations[EnvResourceByDev]      = strings.Join(totals, ",")
+	newPod.ObjectMeta.Annotations[EnvResourceByPod]      = fmt.Sprintf("%d", xpuShares)
+	newPod.ObjectMeta.Annotations[EnvResourceByDevIndex] = strings.Join(sharesByDev, ",")
 	newPod.ObjectMeta.Annotations[EnvAssignedFlag]       = "false"
 	newPod.ObjectMeta.Annotations[EnvResourceAssumeTime] = fmt.Sprintf("%d", now.UnixNano())
 
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func containerWith(name v1.ResourceName, value int64) v1.Container {
+	return v1.Container{Resources: v1.ResourceRequirements{Limits: v1.ResourceList{
+		name: *resource.NewQuantity(value, resource.DecimalSI),
+	}}}
+}
+
+func TestGetRequestFromPodResource(t *testing.T) {
+	tests := []struct {
+		name       string
+		resource   v1.ResourceName
+		containers []int64
+		inits      []int64
+		request    int
+	}{
+		{name: "sum of the containers", resource: ResourceName, containers: []int64{4, 6}, request: 10},
+		{name: "the largest init container", resource: ResourceName, containers: []int64{4, 6}, inits: []int64{12, 3}, request: 12},
+		{name: "init container smaller than the sum", resource: ResourceName, containers: []int64{4, 6}, inits: []int64{8}, request: 10},
+		{name: "only init containers", resource: ResourceName, inits: []int64{2, 5}, request: 5},
+		{name: "whole devices of the init container", resource: CountName, containers: []int64{1}, inits: []int64{2}, request: 2},
+		{name: "whole devices of the containers", resource: CountName, containers: []int64{1, 2}, inits: []int64{2}, request: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := &v1.Pod{}
+			for _, value := range test.containers {
+				pod.Spec.Containers = append(pod.Spec.Containers, containerWith(test.resource, value))
+			}
+			for _, value := range test.inits {
+				pod.Spec.InitContainers = append(pod.Spec.InitContainers, containerWith(test.resource, value))
+			}
+			request := GetRequestXPUSharesFromPodResource(pod)
+			if test.resource == CountName {
+				request = GetRequestXPUCountsFromPodResource(pod)
+			}
+			if request != test.request {
+				t.Errorf("request %d, want %d", request, test.request)
+			}
+		})
+	}
+}